@@ -0,0 +1,131 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// This file defines the -baseline feature, which suppresses the
+// dead functions recorded by an earlier run so that only newly
+// dead functions are reported.
+//
+// A baseline file has the same form as the output of -json. Files
+// written before the output had a schema version, which hold a bare
+// array of packages, are accepted too, as is the output of -jsonl.
+
+// It also defines the -allow feature, which suppresses the dead
+// functions listed by name in an allowlist file, such as debugging
+// aids that are intentionally unused in release builds.
+
+// A baselineKey identifies a dead function independent of its
+// position, so that a baseline survives edits that shift lines.
+type baselineKey struct {
+	pkgpath, name string
+}
+
+// readBaseline returns the set of functions recorded in the
+// specified baseline file.
+func readBaseline(filename string) (map[baselineKey]bool, error) {
+	packages, err := readReport(filename)
+	if err != nil {
+		return nil, err
+	}
+	baseline := make(map[baselineKey]bool)
+	for _, p := range packages {
+		for _, f := range p.Funcs {
+			baseline[baselineKey{p.Path, f.Name}] = true
+		}
+	}
+	return baseline, nil
+}
+
+// readReport returns the packages of the report in the specified
+// file, which holds the output of -json, -json-legacy, or -jsonl.
+func readReport(filename string) ([]jsonPackage, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var packages []jsonPackage
+		if err := json.Unmarshal(data, &packages); err != nil { // legacy form
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		return packages, nil
+	}
+
+	// The file holds a report, or a stream of packages (-jsonl).
+	var packages []jsonPackage
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var value struct {
+			SchemaVersion int           `json:"schemaVersion"`
+			Packages      []jsonPackage `json:"packages"`
+			jsonPackage
+		}
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		if value.SchemaVersion > schemaVersion {
+			return nil, fmt.Errorf("%s: unsupported schema version %d", filename, value.SchemaVersion)
+		}
+		if value.SchemaVersion > 0 {
+			packages = append(packages, value.Packages...)
+		} else {
+			packages = append(packages, value.jsonPackage)
+		}
+	}
+	return packages, nil
+}
+
+// writeBaseline records the dead functions of the specified
+// packages in a baseline file.
+func writeBaseline(filename string, packages []any) error {
+	if packages == nil {
+		packages = []any{} // "[]", not "null"
+	}
+	data, err := json.MarshalIndent(jsonReport{schemaVersion, packages}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0666)
+}
+
+// readAllowlist returns the set of functions listed in the specified
+// allowlist file, or the standard input if the name is "-". Each
+// non-blank line that does not start with '#' is a function name in
+// the notation of -entry. The set holds the names in the form
+// "pkgpath.name", where name is as reported by the tool, such as
+// example.com/pkg.T.Method.
+func readAllowlist(filename string) (map[string]bool, error) {
+	names, err := readPatterns(filename)
+	if err != nil {
+		return nil, err
+	}
+	allow := make(map[string]bool)
+	for _, name := range names {
+		// Remove the parens (and star) around the receiver type
+		// of a method: "pkg.(*T).Method" becomes "pkg.T.Method".
+		if i := strings.Index(name, ".("); i >= 0 {
+			recv, method, ok := strings.Cut(name[i+len(".("):], ").")
+			if !ok {
+				return nil, fmt.Errorf("%s: invalid method name %q", filename, name)
+			}
+			name = name[:i+len(".")] + strings.TrimPrefix(recv, "*") + "." + method
+		}
+		if !strings.Contains(name, ".") {
+			return nil, fmt.Errorf("%s: invalid function name %q", filename, name)
+		}
+		allow[name] = true
+	}
+	return allow, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"debug/elf"
+	"debug/gosym"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/deadcode"
+)
+
+// This file defines the -binary feature, which cross-checks the
+// results of the analysis against the functions that the linker kept
+// in an executable built from the program, to validate the analysis
+// on real artifacts.
+//
+// A function that the analysis reports dead but that is present in
+// the executable suggests that the analysis missed a root, though
+// the linker is conservative too: it keeps, for example, the exported
+// methods of types that may be called through reflection. Conversely,
+// a reachable function may be absent because it was inlined at every
+// call site, or because the linker proved it unreachable.
+
+// readSymbols returns the set of names of the functions in the
+// symbol table of the Go executable in the specified file, such as
+// "example.com/p.(*T).f". It reads the table that the runtime itself
+// uses, which survives stripping.
+func readSymbols(filename string) (map[string]bool, error) {
+	data, textStart, err := readPCLineTable(filename)
+	if err != nil {
+		return nil, err
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, textStart))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	symbols := make(map[string]bool)
+	for _, fn := range table.Funcs {
+		symbols[fn.Name] = true
+	}
+	return symbols, nil
+}
+
+// readPCLineTable returns the contents of the pclntab section of the
+// ELF, Mach-O, or PE executable in the specified file, and the
+// address of the start of its text.
+func readPCLineTable(filename string) ([]byte, uint64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	if exe, err := elf.NewFile(f); err == nil {
+		sect, text := exe.Section(".gopclntab"), exe.Section(".text")
+		if sect == nil || text == nil {
+			return nil, 0, fmt.Errorf("%s: no Go symbol table", filename)
+		}
+		data, err := sect.Data()
+		return data, text.Addr, err
+	}
+	if exe, err := macho.NewFile(f); err == nil {
+		sect, text := exe.Section("__gopclntab"), exe.Section("__text")
+		if sect == nil || text == nil {
+			return nil, 0, fmt.Errorf("%s: no Go symbol table", filename)
+		}
+		data, err := sect.Data()
+		return data, text.Addr, err
+	}
+	if exe, err := pe.NewFile(f); err == nil {
+		// PE files have no pclntab section, so find the
+		// table through the symbols that delimit it.
+		var start, end *pe.Symbol
+		for _, sym := range exe.Symbols {
+			switch sym.Name {
+			case "runtime.pclntab":
+				start = sym
+			case "runtime.epclntab":
+				end = sym
+			}
+		}
+		text := exe.Section(".text")
+		if start == nil || end == nil || start.SectionNumber != end.SectionNumber || text == nil {
+			return nil, 0, fmt.Errorf("%s: no Go symbol table", filename)
+		}
+		sect := exe.Sections[start.SectionNumber-1]
+		data, err := sect.Data()
+		if err != nil {
+			return nil, 0, err
+		}
+		if end.Value > uint32(len(data)) || start.Value > end.Value {
+			return nil, 0, fmt.Errorf("%s: invalid Go symbol table", filename)
+		}
+		var imageBase uint64
+		switch hdr := exe.OptionalHeader.(type) {
+		case *pe.OptionalHeader32:
+			imageBase = uint64(hdr.ImageBase)
+		case *pe.OptionalHeader64:
+			imageBase = hdr.ImageBase
+		}
+		return data[start.Value:end.Value], imageBase + uint64(text.VirtualAddress), nil
+	}
+	return nil, 0, fmt.Errorf("%s: not an ELF, Mach-O, or PE executable", filename)
+}
+
+// symbolName returns the name of the function in the symbol table
+// of an executable, such as "example.com/p.(*T).f", or "" if the
+// function has no predictable name, as with initializers and the
+// methods of generic types.
+func symbolName(pkg deadcode.Package, fn deadcode.Function) string {
+	if fn.Kind != "func" || isInit(fn.Name) || strings.Contains(fn.Recv, "[") {
+		return ""
+	}
+	prefix := pkg.Path
+	if pkg.Name == "main" {
+		prefix = "main" // the linker renames all main packages
+	}
+	if fn.Recv == "" {
+		return prefix + "." + fn.Name
+	}
+	method := fn.Name[strings.LastIndexByte(fn.Name, '.')+1:]
+	recv := fn.Recv
+	if strings.HasPrefix(recv, "*") {
+		recv = "(" + recv + ")"
+	}
+	return prefix + "." + recv + "." + method
+}
+
+// crossCheck compares the results of the analysis with the symbols of
+// an executable, and returns the functions of the selected packages
+// that are dead but present, or reachable but absent.
+//
+// The linker names each instantiation of a generic function after its
+// type arguments, such as "example.com/p.F[go.shape.int]", so a
+// generic function is deemed present if any instantiation of it is.
+func crossCheck(dead, live *deadcode.Findings, symbols map[string]bool) []any {
+	generics := make(map[string]bool) // names of instantiated functions, sans type arguments
+	for sym := range symbols {
+		if i := strings.IndexByte(sym, '['); i >= 0 {
+			generics[sym[:i]] = true
+		}
+	}
+	var objects []any
+	check := func(found *deadcode.Findings, isDead bool) {
+		filters, excludes := packageFilters(found.Modules)
+		for _, pkg := range found.Packages {
+			if !selected(filters, excludes, pkg.Path) {
+				continue
+			}
+			for _, fn := range pkg.Funcs {
+				sym := symbolName(pkg, fn)
+				present := symbols[sym]
+				if strings.HasPrefix(fn.Signature, "func[") {
+					present = generics[sym]
+				}
+				if sym != "" && present == isDead {
+					objects = append(objects, jsonBinaryFunc{
+						Name:     fn.Name,
+						Position: toJSONPosition(fn.Position),
+						Symbol:   sym,
+						Dead:     isDead,
+					})
+				}
+			}
+		}
+	}
+	check(dead, true)
+	check(live, false)
+	return objects
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"golang.org/x/tools/go/deadcode"
+	"golang.org/x/tools/go/packages"
+)
+
+// This file defines the -ssa-cache feature, which saves the findings
+// of the analysis so that later runs over the same inputs (perhaps
+// with different -filter flags or output formats) need not load and
+// analyze the program again.
+//
+// The cache is keyed by the command-line patterns, the flags that
+// affect the analysis, and the name, size, and modification time of
+// every file of every package of the program, which are cheap to
+// obtain compared to parsing and type-checking them.
+
+// cacheVersion identifies the format of cache entries.
+// Increment it whenever the findings type changes, or the
+// analysis may find something different in the same program.
+const cacheVersion = 13
+
+// cacheFileName returns the name of the cache entry, within the
+// specified directory, for the findings of the packages denoted by
+// the patterns, and their tests if requested, in the specified build
+// configuration.
+func cacheFileName(dir string, patterns []string, tests bool, bc buildConfig) (string, error) {
+	mode := packages.NeedName | packages.NeedFiles | packages.NeedEmbedFiles |
+		packages.NeedImports | packages.NeedDeps | packages.NeedModule
+	cfg := loadConfig(mode, tests, bc.tags)
+	if len(bc.env) > 0 {
+		cfg.Env = append(os.Environ(), bc.env...)
+	}
+	initial, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "deadcode cache %d %s %s\n", cacheVersion, runtime.Version(), version())
+	fmt.Fprintf(h, "patterns=%q\n", patterns)
+	fmt.Fprintf(h, "buildflags=%q env=%q\n", buildFlags, bc.env)
+	fmt.Fprintf(h, "generated=%q callers=%t\n", *generatedExpr, *genCallers)
+	fmt.Fprintf(h, "depth=%d reachable=%t ifaces=%t roots=%q nodedup=%t blank=%t\n", *quickDepth, *reportLive, *ifaceFlag, *rootExpr, *noDedup, *blankFlag)
+	fmt.Fprintf(h, "test=%t tags=%q algo=%s reflect=%s methods=%t dynamic=%t vars=%t fields=%t types=%t cases=%t entry=%q lib=%t\n",
+		tests, bc.tags, *algoFlag, *reflectFlag, *methodsFlag, *dynamicOnly, *varsFlag, *fieldsFlag, *typesFlag, *casesFlag, entryFlag, *libFlag)
+
+	var files []string
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		fmt.Fprintf(h, "package %s\n", p.ID)
+		files = append(files, p.GoFiles...)
+		files = append(files, p.OtherFiles...)
+		files = append(files, p.EmbedFiles...)
+		if p.Module != nil && p.Module.GoMod != "" {
+			files = append(files, p.Module.GoMod)
+		}
+	})
+	sort.Strings(files)
+	for i, file := range files {
+		if i > 0 && file == files[i-1] {
+			continue // duplicate
+		}
+		if info, err := os.Stat(file); err != nil {
+			fmt.Fprintf(h, "file %s missing\n", file)
+		} else {
+			fmt.Fprintf(h, "file %s %d %d\n", file, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return filepath.Join(dir, fmt.Sprintf("%x.json", h.Sum(nil))), nil
+}
+
+// readCache returns the findings saved in the specified cache entry,
+// or nil if there is no valid entry.
+func readCache(filename string) *deadcode.Findings {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	var found deadcode.Findings
+	if err := json.Unmarshal(data, &found); err != nil {
+		return nil // corrupt entry; recompute it
+	}
+	return &found
+}
+
+// writeCache saves the findings in the specified cache entry.
+func writeCache(filename string, found *deadcode.Findings) error {
+	data, err := json.Marshal(found)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+	// Write atomically, in case of a concurrent run.
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
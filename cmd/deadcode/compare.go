@@ -0,0 +1,125 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// This file defines the -compare feature, which reports the changes
+// in the dead code since an earlier run, whose output was saved with
+// -json or -jsonl, so that the tool can track a trend.
+
+// A comparison holds the dead functions of the current run, and those
+// of an earlier run, classified by how they changed.
+type comparison struct {
+	NewlyDead    []jsonPackage // dead now but not before
+	NoLongerDead []jsonPackage // dead before but not now: deleted, or now reachable
+	StillDead    []jsonPackage // dead both before and now
+}
+
+// compare classifies the dead functions of the current and previous
+// runs. A function is identified by its ID, or if the previous run
+// predates IDs, by its package path and name.
+func compare(previous []jsonPackage, packages []any) comparison {
+	key := func(pkgpath string, f jsonFunction) string {
+		if f.ID != "" {
+			return f.ID
+		}
+		return pkgpath + "." + f.Name
+	}
+	// Record the keys of current functions under both
+	// forms, to match previous functions of either kind.
+	current := make(map[string]bool)
+	for _, p := range packages {
+		p := p.(jsonPackage)
+		for _, f := range p.Funcs {
+			current[f.ID] = true
+			current[p.Path+"."+f.Name] = true
+		}
+	}
+	before := make(map[string]bool)
+	for _, p := range previous {
+		for _, f := range p.Funcs {
+			before[key(p.Path, f)] = true
+		}
+	}
+
+	var cmp comparison
+	for _, p := range packages {
+		p := p.(jsonPackage)
+		var newly, still []jsonFunction
+		for _, f := range p.Funcs {
+			if before[f.ID] || before[p.Path+"."+f.Name] {
+				still = append(still, f)
+			} else {
+				newly = append(newly, f)
+			}
+		}
+		cmp.NewlyDead = appendPackage(cmp.NewlyDead, p, newly)
+		cmp.StillDead = appendPackage(cmp.StillDead, p, still)
+	}
+	for _, p := range previous {
+		var fixed []jsonFunction
+		for _, f := range p.Funcs {
+			if !current[key(p.Path, f)] {
+				fixed = append(fixed, f)
+			}
+		}
+		cmp.NoLongerDead = appendPackage(cmp.NoLongerDead, p, fixed)
+	}
+	return cmp
+}
+
+// appendPackage appends to packages a copy of p holding only the
+// specified functions, if there are any.
+func appendPackage(packages []jsonPackage, p jsonPackage, funcs []jsonFunction) []jsonPackage {
+	if funcs == nil {
+		return packages
+	}
+	return append(packages, jsonPackage{Name: p.Name, Path: p.Path, Funcs: funcs})
+}
+
+// printComparison prints the comparison of the dead functions of the
+// current and previous runs, as a JSON object with -json, or otherwise
+// in three sections.
+func printComparison(previous []jsonPackage, packages []any) {
+	cmp := compare(previous, packages)
+	if *jsonFlag {
+		out, err := marshalJSON(cmp)
+		if err != nil {
+			log.Fatalf("internal error: %v", err)
+		}
+		stdout.Write(out)
+		return
+	}
+	for _, section := range []struct {
+		title    string
+		packages []jsonPackage
+		stale    bool // positions are those of the previous run
+	}{
+		{"newly dead", cmp.NewlyDead, false},
+		{"no longer dead", cmp.NoLongerDead, true},
+		{"still dead", cmp.StillDead, false},
+	} {
+		n := 0
+		for _, p := range section.packages {
+			n += len(p.Funcs)
+		}
+		fmt.Fprintf(stdout, "%s (%d):\n", section.title, n)
+		for _, p := range section.packages {
+			for _, f := range p.Funcs {
+				if section.stale {
+					fmt.Fprintf(stdout, "\t%s %s.%s\n", f.Kind, p.Path, f.Name)
+				} else {
+					fmt.Fprintf(stdout, "\t%s: %s %s.%s\n", f.Position, f.Kind, p.Path, f.Name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// This file defines the -config feature, which reads the default
+// values of flags from a JSON file, so that a project can keep its
+// policy, such as filters and allowlists, under version control.
+//
+// A config file holds a JSON object whose keys are flag names, sans
+// hyphen, and whose values are strings, numbers, or booleans, or, for
+// a flag that may be repeated, arrays of them:
+//
+//	{
+//		"filter": ["example.com/repo/internal"],
+//		"tags": "integration",
+//		"generated": true
+//	}
+//
+// Flags specified on the command line take precedence.
+
+// defaultConfig is the name of the config file read if -config is unset.
+const defaultConfig = "deadcode.json"
+
+// applyConfig sets the flags not specified on the command line to
+// the values in the specified config file, or, if the name is empty,
+// in deadcode.json, if that file exists.
+func applyConfig(filename string) error {
+	if filename == "" {
+		filename = defaultConfig
+		if _, err := os.Stat(filename); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var values map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // preserve the text of numbers
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	explicit := make(map[string]bool) // flags set on the command line
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	names := keys(values)
+	sort.Strings(names)
+	for _, name := range names {
+		f := flag.Lookup(name)
+		if f == nil || name == "config" {
+			return fmt.Errorf("%s: unknown flag %q", filename, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		elems := []any{values[name]}
+		if list, ok := values[name].([]any); ok {
+			if _, ok := f.Value.(*stringList); !ok {
+				return fmt.Errorf("%s: flag %q may not be repeated", filename, name)
+			}
+			elems = list
+		}
+		for _, elem := range elems {
+			switch elem.(type) {
+			case string, bool, json.Number:
+			default:
+				return fmt.Errorf("%s: invalid value for flag %q: %v", filename, name, elem)
+			}
+			if err := flag.Set(name, fmt.Sprint(elem)); err != nil {
+				return fmt.Errorf("%s: invalid value for flag %q: %v", filename, name, err)
+			}
+		}
+	}
+	return nil
+}
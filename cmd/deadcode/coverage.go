@@ -0,0 +1,59 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"path"
+	"path/filepath"
+
+	"golang.org/x/tools/cover"
+)
+
+// This file defines the -coverprofile feature, which, with
+// -report-reachable, reconciles the static view of the program with
+// the dynamic one recorded in a coverage profile, by reporting the
+// reachable functions that were never executed.
+
+// A coverage holds the blocks of a coverage profile, indexed by file
+// name as it appears in the profile: the import path of the package,
+// followed by the base name of the file, such as "example.com/p/p.go".
+type coverage map[string][]cover.ProfileBlock
+
+// readCoverage reads the coverage profile in the specified file,
+// as written by "go test -coverprofile".
+func readCoverage(filename string) (coverage, error) {
+	profiles, err := cover.ParseProfiles(filename)
+	if err != nil {
+		return nil, err
+	}
+	cov := make(coverage)
+	for _, p := range profiles {
+		cov[p.FileName] = append(cov[p.FileName], p.Blocks...)
+	}
+	return cov, nil
+}
+
+// executed reports whether any block of code within the function, in
+// the package with the specified path, was executed according to the
+// profile. If the profile holds no blocks within the function, as when
+// its package was not instrumented, the coverage is unknown, and known
+// is false.
+func (cov coverage) executed(pkgpath string, f jsonFunction) (executed, known bool) {
+	end := f.EndLine
+	if end == 0 {
+		end = f.Position.Line // end unknown
+	}
+	for _, b := range cov[path.Join(pkgpath, filepath.Base(f.Position.File))] {
+		if b.StartLine >= f.Position.Line && b.EndLine <= end {
+			known = true
+			if b.Count > 0 {
+				return true, true
+			}
+		}
+	}
+	return false, known
+}
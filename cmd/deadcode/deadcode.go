@@ -7,32 +7,38 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"go/ast"
 	"go/token"
-	"go/types"
 	"io"
 	"log"
+	"math"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"runtime/debug"
 	"runtime/pprof"
+	"runtime/trace"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"golang.org/x/telemetry"
-	"golang.org/x/tools/go/callgraph"
-	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/deadcode"
 	"golang.org/x/tools/go/packages"
-	"golang.org/x/tools/go/ssa"
-	"golang.org/x/tools/go/ssa/ssautil"
-	"golang.org/x/tools/internal/typesinternal"
 )
 
 //go:embed doc.go
@@ -40,18 +46,116 @@ var doc string
 
 // flags
 var (
-	testFlag = flag.Bool("test", false, "include implicit test packages and executables")
-	tagsFlag = flag.String("tags", "", "comma-separated list of extra build tags (see: go help buildconstraint)")
-
-	filterFlag    = flag.String("filter", "<module>", "report only packages matching this regular expression (default: module of first package)")
+	configFlag    = flag.String("config", "", "read default values of flags from this JSON file (default: deadcode.json, if present)")
+	testFlag      = flag.Bool("test", false, "include implicit test packages and executables")
+	testsOnlyFlag = flag.Bool("include-tests-only", false, "report only functions that are reachable from tests alone")
+	tagsFlag      = flag.String("tags", "", "comma-separated list of extra build tags (see: go help buildconstraint)")
+	buildFlags    stringList // see init
+	pkgFileFlag   = flag.String("pkgfile", "", "read additional package patterns, one per line, from this file (or - for stdin)")
+	entryFile     = flag.String("entry-file", "", "read additional -entry functions from this file, which holds a JSON array of names")
+
+	entryFlag     stringList // see init
+	rootExpr      = flag.String("root-regexp", "", "treat the functions of the packages whose names, such as Server.HandleLogin, match this regular expression as additional roots")
+	libFlag       = flag.Bool("lib", false, "if there are no main packages, treat the exported functions and methods of the packages as roots")
+	filterFlag    stringList // see init
+	filterGlob    stringList // see init
+	excludeFlag   stringList // see init
+	excludeFunc   stringList // see init
+	skipFiles     stringList // see init
+	onlyFlag      = flag.String("only", "", "report only the packages with these comma-separated import paths, after -filter")
 	generatedFlag = flag.Bool("generated", false, "include dead functions in generated Go files")
+	genCallers    = flag.Bool("ignore-generated-callers", false, "ignore calls from generated Go files, reporting functions called only by generated code")
+	generatedExpr = flag.String("generated-regexp", "", "also treat Go files with a header comment matching this regular expression as generated")
+	methodsFlag   = flag.Bool("methods", false, "also report exported methods that are reachable but never called")
+	varsFlag      = flag.Bool("vars", false, "also report package-level variables and constants not used by reachable code")
+	fieldsFlag    = flag.Bool("fields", false, "also report struct fields not read by reachable code")
+	typesFlag     = flag.Bool("types", false, "also report package-level named types not used by reachable code")
+	casesFlag     = flag.Bool("switch-cases", false, "also report type switch cases and type assertions that can never succeed")
+	ifaceFlag     = flag.Bool("iface-methods", false, "also report methods of interface types that are never called through any interface")
+	blankFlag     = flag.Bool("blank-imports", false, "also report blank imports of packages whose initialization appears to have no effect (approximate)")
+	lineCountFlag = flag.Bool("line-count", false, "show the number of lines of each dead function")
+	contextFlag   = flag.Int("context", 0, "show the first n source lines of each dead function")
+	noInitFlag    = flag.Bool("no-init", false, "do not report dead init functions")
+	noVendorFlag  = flag.Bool("no-vendor", true, "do not report dead functions in vendored packages")
+	noTestFiles   = flag.Bool("exclude-test-files", false, "do not report dead functions declared in _test.go files")
+	dynamicOnly   = flag.Bool("dynamic-only", false, "report reachable functions that are called only dynamically, instead of dead ones")
+	reportLive    = flag.Bool("report-reachable", false, "report the reachable functions instead of dead ones")
+	coverFlag     = flag.String("coverprofile", "", "with -report-reachable, report only the functions never executed according to this coverage profile")
+	keepExported  = flag.Bool("keep-exported", false, "do not report exported functions and methods of exported types, which are part of a package's API")
+	unexpOnly     = flag.Bool("unexported-only", false, "report only functions and methods with unexported names")
+	excludeMain   = flag.Bool("exclude-main", false, "do not report functions in main packages, though they are still roots")
+	minLinesFlag  = flag.Int("min-lines", 0, "report only dead functions spanning at least this many lines")
+	showIgnored   = flag.Bool("show-ignored", false, "list dead functions suppressed by //deadcode:ignore comments on stderr")
+	baselineFlag  = flag.String("baseline", "", "report only dead functions not recorded in this JSON file")
+	baselineWrite = flag.Bool("baseline-write", false, "record the dead functions in the -baseline file instead of reporting them")
+	compareFlag   = flag.String("compare", "", "report the changes in the dead functions since the run whose -json or -jsonl output is in this file")
+	allowFlag     = flag.String("allow", "", "never report the functions listed in this file, one per line, such as example.com/pkg.(*T).Method")
+	diffFlag      = flag.String("diff", "", "report only dead functions in files changed since this git revision (e.g. origin/main)")
+	changedFiles  = flag.String("changed-files", "", "report only dead functions in the files listed in this file, one per line")
+	dedupFlag     = flag.String("dedup-by", "position", "coalesce dead functions with the same position, or also the same name (position or name)")
+	noDedup       = flag.Bool("no-dedup", false, "report each unreachable variant of a function, such as one compiled for a test, separately (for debugging the analysis)")
+	groupFlag     = flag.String("group", "package", "group dead functions by package, or by file or kind within each package (package, file, or kind)")
+	sortFlag      = flag.String("sort", "pos", "order dead functions within each package by position, name, or size (pos, name, or size)")
 	whyLiveFlag   = flag.String("whylive", "", "show a path from main to the named function")
+	ifRemovedFlag = flag.String("if-removed", "", "report the functions that would become dead if the named function were deleted")
+	algoFlag      = flag.String("algo", "rta", "call graph algorithm for computing reachability (rta or cha)")
+	quickFlag     = flag.Bool("quick", false, "follow only static calls, for fast but approximate results")
+	quickDepth    = flag.Int("quick-depth", 0, "with -quick, follow static calls only to this depth from the roots (0 means no limit)")
+	reflectFlag   = flag.String("reflect", "precise", "treatment of methods called by name through reflection (precise or conservative)")
 	formatFlag    = flag.String("f", "", "format output records using template")
+	colorFlag     = flag.String("color", "auto", "highlight the text output (auto, always, or never; auto means only on a terminal)")
+	linksFlag     = flag.Bool("links", false, "show positions in the text output as hyperlinks to the file, on a terminal")
+	ssaCacheFlag  = flag.String("ssa-cache", "", "cache the analysis results in this directory, reusing them while the inputs are unchanged")
+	formatFile    = flag.String("format-file", "", "format output records using template read from this file")
+	formatAll     = flag.Bool("format-all", false, "execute the -f template once, on the list of all records, instead of once per record")
+	outputFlag    = flag.String("o", "", "write the report to this file instead of the standard output")
 	jsonFlag      = flag.Bool("json", false, "output JSON records")
+	jsonCompact   = flag.Bool("json-compact", false, "output JSON records like -json, but on a single line, without indentation")
+	jsonLegacy    = flag.Bool("json-legacy", false, "output JSON records as a bare array, without the schema version (deprecated)")
+	jsonlFlag     = flag.Bool("jsonl", false, "output JSON records, one per line (JSON Lines)")
+	sarifFlag     = flag.Bool("sarif", false, "output a SARIF 2.1.0 log (for code scanning tools)")
+	csvFlag       = flag.Bool("csv", false, "output CSV records, one per dead function, with a header row")
+	countFlag     = flag.Bool("count", false, "print only the number of dead functions and packages")
+	dirSummary    = flag.Bool("summary-by-dir", false, "print only the number of dead functions in each directory, largest first")
+	statsFlag     = flag.Bool("stats", false, "also print the number of reachable functions and the percentage that are dead")
+	dotFlag       = flag.Bool("dot", false, "print the reachable call graph in GraphViz DOT format")
+	reachFlag     = flag.Bool("reachable-from", false, "show, for each reachable package, the main packages that reach it")
+	explainFlag   = flag.String("explain-package", "", "show, for each function of the package with this import path, whether it is reachable and a caller")
+	rootsFlag     = flag.Bool("print-roots", false, "print the roots of the analysis, such as main and init functions, instead of the dead code")
+	binaryFlag    = flag.String("binary", "", "cross-check the analysis against the functions in the symbol table of this Go executable")
+	dotDepthFlag  = flag.Int("dot-depth", 0, "with -dot, limit the call graph to this many calls from a root (0 = unlimited)")
+	relativeFlag  = flag.Bool("relative", false, "report file names relative to the root of the module of the first package")
+	trimPrefix    = flag.String("trim-prefix", "", "report file names relative to this directory (default: the current directory)")
+	exitFlag      = flag.Bool("set-exit-status", false, "exit with status 3 if any dead code is reported")
+	maxFlag       = flag.Int("max", -1, "fail only if more than n dead functions are reported (-1 means no limit)")
+	timeoutFlag   = flag.Duration("timeout", 0, "abandon the analysis if it takes longer than this duration, such as 5m (0 means no limit)")
+	timeoutExit   = flag.Int("timeout-exit", 1, "with -timeout, the exit status if the analysis is abandoned")
+	matrixFlag    = flag.String("tags-matrix", "", "analyze each of these comma-separated GOOS/GOARCH configurations, reporting code dead in all of them")
+	tagsDiffFlag  = flag.String("tags-diff", "", "report code that is live with these comma-separated build tags but dead without them")
+	watchFlag     = flag.Bool("watch", false, "report the dead code again whenever the program's Go files change, until interrupted")
+	parallelFlag  = flag.Int("p", runtime.GOMAXPROCS(0), "maximum number of main packages to analyze in parallel")
+	verboseFlag   = flag.Bool("v", false, "log the progress of each phase of the analysis")
+	quietFlag     = flag.Bool("q", false, "print only the dead code and errors, not warnings")
 	cpuProfile    = flag.String("cpuprofile", "", "write CPU profile to this file")
 	memProfile    = flag.String("memprofile", "", "write memory profile to this file")
+	traceFlag     = flag.String("trace", "", "write execution trace to this file")
+	memLimitFlag  = flag.String("memlimit", "", "soft limit on the memory used by the Go runtime, such as 4GiB (see GOMEMLIMIT)")
 )
 
+// stdout is the destination of the report; see -o.
+var stdout io.Writer = os.Stdout
+
+func init() {
+	flag.BoolVar(exitFlag, "c", false, "shorthand for -set-exit-status")
+	flag.Var(&buildFlags, "buildflag", "pass this flag, such as -mod=mod, to the build system when loading packages; may be repeated")
+	flag.Var(&entryFlag, "entry", "treat the named function, such as example.com/pkg.(*T).Method, as an additional root; may be repeated")
+	flag.Var(&filterFlag, "filter", "report only packages matching this regular expression (default: module of first package); may be repeated")
+	flag.Var(&filterGlob, "filter-glob", "report only packages matching this pattern, such as example.com/repo/internal/...; may be repeated")
+	flag.Var(&excludeFlag, "exclude", "do not report packages matching this regular expression; may be repeated")
+	flag.Var(&skipFiles, "skip-files", "do not report functions declared in files whose name matches this regular expression; may be repeated")
+	flag.Var(&excludeFunc, "exclude-func", "do not report functions whose name, such as (*T).Method, matches this regular expression; may be repeated")
+}
+
 func usage() {
 	// Extract the content of the /* ... */ comment in doc.go.
 	_, after, _ := strings.Cut(doc, "/*\n")
@@ -63,6 +167,34 @@ Flags:
 	flag.PrintDefaults()
 }
 
+// flagConflicts lists the flags that may not be used together: each
+// entry names a flag and the flags that are incompatible with it, as
+// keyed in the table of flags in use built by main. The first
+// conflict found, in table order, is reported.
+var flagConflicts = []struct {
+	flag string
+	with []string
+}{
+	{"-whylive", []string{"-sarif", "-csv", "-count", "-summary-by-dir", "-dot"}},
+	{"-include-tests-only", []string{"-test", "-whylive", "-dot"}},
+	{"-if-removed", []string{"-whylive", "-dot", "-reachable-from", "-tags-matrix", "-tags-diff", "-watch", "-include-tests-only", "-dynamic-only", "-report-reachable", "-baseline-write"}},
+	{"-explain-package", []string{"-whylive", "-dot", "-reachable-from", "-if-removed", "-print-roots", "-sarif", "-csv", "-count", "-tags-matrix", "-tags-diff", "-watch"}},
+	{"-binary", []string{"-whylive", "-dot", "-reachable-from", "-if-removed", "-explain-package", "-print-roots", "-report-reachable", "-dynamic-only", "-sarif", "-csv", "-count", "-tags-matrix", "-tags-diff", "-watch"}},
+	{"-print-roots", []string{"-whylive", "-dot", "-reachable-from", "-if-removed", "-sarif", "-csv", "-count", "-tags-matrix", "-tags-diff", "-watch"}},
+	{"-reachable-from", []string{"-whylive", "-dot", "-sarif", "-csv", "-count", "-tags-matrix", "-tags-diff", "-watch"}},
+	{"-compare", []string{"-baseline-write", "-f=template", "-jsonl", "-sarif", "-csv", "-count", "-summary-by-dir", "-whylive", "-dot", "-reachable-from"}},
+	{"-q", []string{"-v"}},
+	{"-dynamic-only", []string{"-whylive", "-dot", "-include-tests-only", "-sarif", "-stats"}},
+	{"-report-reachable", []string{"-dynamic-only", "-whylive", "-dot", "-include-tests-only", "-sarif", "-stats"}},
+	{"-watch", []string{"-whylive", "-dot", "-baseline-write", "-timeout"}},
+	{"-tags-matrix", []string{"-dot", "-whylive"}},
+	{"-tags-diff", []string{"-tags-matrix", "-dot", "-whylive"}},
+	{"-no-dedup", []string{"-dedup-by=name", "-report-reachable", "-dynamic-only"}},
+	{"-quick", []string{"-algo"}},
+	{"-diff", []string{"-changed-files"}},
+	{"-relative", []string{"-trim-prefix"}},
+}
+
 func main() {
 	telemetry.Start(telemetry.Config{ReportCrashes: true})
 
@@ -71,11 +203,31 @@ func main() {
 
 	flag.Usage = usage
 	flag.Parse()
-	if len(flag.Args()) == 0 {
+	if err := applyConfig(*configFlag); err != nil {
+		log.Fatalf("-config: %v", err)
+	}
+	patterns := flag.Args()
+	if *pkgFileFlag != "" {
+		more, err := readPatterns(*pkgFileFlag)
+		if err != nil {
+			log.Fatalf("-pkgfile: %v", err)
+		}
+		patterns = append(patterns, more...)
+	}
+	if *entryFile != "" {
+		more, err := readEntries(*entryFile)
+		if err != nil {
+			log.Fatalf("-entry-file: %v", err)
+		}
+		entryFlag = append(entryFlag, more...)
+	}
+	if len(patterns) == 0 {
 		usage()
 		os.Exit(2)
 	}
 
+	defer atExit.run()
+
 	if *cpuProfile != "" {
 		f, err := os.Create(*cpuProfile)
 		if err != nil {
@@ -84,8 +236,8 @@ func main() {
 		if err := pprof.StartCPUProfile(f); err != nil {
 			log.Fatal(err)
 		}
-		// NB: profile won't be written in case of error.
-		defer pprof.StopCPUProfile()
+		// NB: profile won't be written in case of log.Fatal.
+		atExit.add(pprof.StopCPUProfile)
 	}
 
 	if *memProfile != "" {
@@ -93,175 +245,360 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		// NB: profile won't be written in case of error.
-		defer func() {
+		// NB: profile won't be written in case of log.Fatal.
+		atExit.add(func() {
 			runtime.GC() // get up-to-date statistics
 			if err := pprof.WriteHeapProfile(f); err != nil {
 				log.Fatalf("Writing memory profile: %v", err)
 			}
 			f.Close()
-		}()
+		})
 	}
 
-	// Reject bad output options early.
-	if *formatFlag != "" {
-		if *jsonFlag {
-			log.Fatalf("you cannot specify both -f=template and -json")
+	if *traceFlag != "" {
+		f, err := os.Create(*traceFlag)
+		if err != nil {
+			log.Fatal(err)
 		}
-		if _, err := template.New("deadcode").Parse(*formatFlag); err != nil {
-			log.Fatalf("invalid -f: %v", err)
+		if err := trace.Start(f); err != nil {
+			log.Fatal(err)
 		}
+		// NB: trace won't be written in case of log.Fatal.
+		atExit.add(func() {
+			trace.Stop()
+			f.Close()
+		})
 	}
 
-	// Load, parse, and type-check the complete program(s).
-	cfg := &packages.Config{
-		BuildFlags: []string{"-tags=" + *tagsFlag},
-		Mode:       packages.LoadAllSyntax | packages.NeedModule,
-		Tests:      *testFlag,
+	// With -memlimit, make the garbage collector work harder
+	// as the heap approaches the limit.
+	if *memLimitFlag != "" {
+		limit, err := parseBytes(*memLimitFlag)
+		if err != nil {
+			log.Fatalf("invalid -memlimit=%s: %v", *memLimitFlag, err)
+		}
+		debug.SetMemoryLimit(limit)
 	}
-	initial, err := packages.Load(cfg, flag.Args()...)
-	if err != nil {
-		log.Fatalf("Load: %v", err)
+
+	// Reject bad output options early.
+	if *jsonLegacy || *jsonCompact {
+		*jsonFlag = true
 	}
-	if len(initial) == 0 {
-		log.Fatalf("no packages")
+	var formats []string // output format flags in use
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"-f=template", *formatFlag != ""},
+		{"-format-file", *formatFile != ""},
+		{"-json", *jsonFlag},
+		{"-jsonl", *jsonlFlag},
+		{"-sarif", *sarifFlag},
+		{"-csv", *csvFlag},
+		{"-count", *countFlag},
+		{"-summary-by-dir", *dirSummary},
+		{"-dot", *dotFlag},
+	} {
+		if f.set {
+			formats = append(formats, f.name)
+		}
 	}
-	if packages.PrintErrors(initial) > 0 {
-		log.Fatalf("packages contain errors")
+	if len(formats) > 1 {
+		log.Fatalf("you cannot specify both %s and %s", formats[0], formats[1])
 	}
-
-	// If -filter is unset, use first module (if available).
-	if *filterFlag == "<module>" {
-		if mod := initial[0].Module; mod != nil && mod.Path != "" {
-			*filterFlag = "^" + regexp.QuoteMeta(mod.Path) + "\\b"
-		} else {
-			*filterFlag = "" // match any
+	used := map[string]bool{
+		"-algo":               *algoFlag != "rta",
+		"-baseline-write":     *baselineWrite,
+		"-binary":             *binaryFlag != "",
+		"-changed-files":      *changedFiles != "",
+		"-compare":            *compareFlag != "",
+		"-count":              *countFlag,
+		"-csv":                *csvFlag,
+		"-dedup-by=name":      *dedupFlag == "name",
+		"-diff":               *diffFlag != "",
+		"-dot":                *dotFlag,
+		"-dynamic-only":       *dynamicOnly,
+		"-explain-package":    *explainFlag != "",
+		"-f=template":         *formatFlag != "" || *formatFile != "",
+		"-if-removed":         *ifRemovedFlag != "",
+		"-include-tests-only": *testsOnlyFlag,
+		"-jsonl":              *jsonlFlag,
+		"-no-dedup":           *noDedup,
+		"-print-roots":        *rootsFlag,
+		"-q":                  *quietFlag,
+		"-quick":              *quickFlag,
+		"-reachable-from":     *reachFlag,
+		"-relative":           *relativeFlag,
+		"-report-reachable":   *reportLive,
+		"-sarif":              *sarifFlag,
+		"-stats":              *statsFlag,
+		"-summary-by-dir":     *dirSummary,
+		"-tags-diff":          *tagsDiffFlag != "",
+		"-tags-matrix":        *matrixFlag != "",
+		"-test":               *testFlag,
+		"-timeout":            *timeoutFlag != 0,
+		"-trim-prefix":        *trimPrefix != "",
+		"-v":                  *verboseFlag,
+		"-watch":              *watchFlag,
+		"-whylive":            *whyLiveFlag != "",
+	}
+	for _, c := range flagConflicts {
+		for _, other := range c.with {
+			if _, ok := used[other]; !ok {
+				log.Fatalf("internal error: no flag %s", other) // table mistake
+			}
+			if used[c.flag] && used[other] {
+				log.Fatalf("you cannot specify both %s and %s", c.flag, other)
+			}
 		}
 	}
-	filter, err := regexp.Compile(*filterFlag)
-	if err != nil {
-		log.Fatalf("-filter: %v", err)
-	}
-
-	// Create SSA-form program representation
-	// and find main packages.
-	prog, pkgs := ssautil.AllPackages(initial, ssa.InstantiateGenerics)
-	prog.Build()
-
-	mains := ssautil.MainPackages(pkgs)
-	if len(mains) == 0 {
-		log.Fatalf("no main packages")
-	}
-	var roots []*ssa.Function
-	for _, main := range mains {
-		roots = append(roots, main.Func("init"), main.Func("main"))
-	}
-
-	// Gather all source-level functions,
-	// as the user interface is expressed in terms of them.
-	//
-	// We ignore synthetic wrappers, and nested functions. Literal
-	// functions passed as arguments to other functions are of
-	// course address-taken and there exists a dynamic call of
-	// that signature, so when they are unreachable, it is
-	// invariably because the parent is unreachable.
-	var sourceFuncs []*ssa.Function
-	generated := make(map[string]bool)
-	packages.Visit(initial, nil, func(p *packages.Package) {
-		for _, file := range p.Syntax {
-			for _, decl := range file.Decls {
-				if decl, ok := decl.(*ast.FuncDecl); ok {
-					obj := p.TypesInfo.Defs[decl.Name].(*types.Func)
-					fn := prog.FuncValue(obj)
-					sourceFuncs = append(sourceFuncs, fn)
-				}
+	if *timeoutFlag < 0 {
+		log.Fatalf("invalid -timeout=%v: must not be negative", *timeoutFlag)
+	}
+	if *timeoutExit < 1 || *timeoutExit > 125 {
+		log.Fatalf("invalid -timeout-exit=%d: must be between 1 and 125", *timeoutExit)
+	}
+	var platforms []string
+	if *matrixFlag != "" {
+		for _, platform := range strings.Split(*matrixFlag, ",") {
+			goos, goarch, ok := strings.Cut(platform, "/")
+			if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+				log.Fatalf("invalid -tags-matrix=%s: %q is not of the form GOOS/GOARCH", *matrixFlag, platform)
 			}
-
-			if isGenerated(file) {
-				generated[p.Fset.File(file.Pos()).Name()] = true
+			platforms = append(platforms, platform)
+		}
+	}
+	var diffTags []string
+	if *tagsDiffFlag != "" {
+		for _, tag := range strings.Split(*tagsDiffFlag, ",") {
+			if tag == "" {
+				log.Fatalf("invalid -tags-diff=%s: empty tag", *tagsDiffFlag)
 			}
+			diffTags = append(diffTags, tag)
 		}
-	})
-
-	// Compute the reachabilty from main.
-	// (Build a call graph only for -whylive.)
-	res := rta.Analyze(roots, *whyLiveFlag != "")
+	}
+	if *onlyFlag != "" {
+		onlyPkgs = make(map[string]bool)
+		for _, pkgpath := range strings.Split(*onlyFlag, ",") {
+			if pkgpath == "" {
+				log.Fatalf("invalid -only=%s: empty package path", *onlyFlag)
+			}
+			onlyPkgs[pkgpath] = true
+		}
+	}
+	if *rootExpr != "" {
+		if _, err := regexp.Compile(*rootExpr); err != nil {
+			log.Fatalf("invalid -root-regexp: %v", err)
+		}
+	}
+	for _, expr := range skipFiles {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Fatalf("invalid -skip-files: %v", err)
+		}
+		skipFileREs = append(skipFileREs, re)
+	}
+	for _, expr := range excludeFunc {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Fatalf("invalid -exclude-func: %v", err)
+		}
+		excludeFuncs = append(excludeFuncs, re)
+	}
+	if *generatedExpr != "" {
+		if _, err := regexp.Compile(*generatedExpr); err != nil {
+			log.Fatalf("invalid -generated-regexp: %v", err)
+		}
+	}
+	if *dedupFlag != "position" && *dedupFlag != "name" {
+		log.Fatalf("unknown -dedup-by=%s: must be position or name", *dedupFlag)
+	}
+	if *groupFlag != "package" && *groupFlag != "file" && *groupFlag != "kind" {
+		log.Fatalf("unknown -group=%s: must be package, file, or kind", *groupFlag)
+	}
+	if *sortFlag != "pos" && *sortFlag != "name" && *sortFlag != "size" {
+		log.Fatalf("unknown -sort=%s: must be pos, name, or size", *sortFlag)
+	}
+	if *algoFlag != "rta" && *algoFlag != "cha" {
+		log.Fatalf("unknown -algo=%s: must be rta or cha", *algoFlag)
+	}
+	if *casesFlag && (*algoFlag == "cha" || *quickFlag) {
+		log.Fatalf("-switch-cases requires -algo=rta")
+	}
+	if *quickDepth < 0 {
+		log.Fatalf("invalid -quick-depth=%d: must not be negative", *quickDepth)
+	}
+	if *quickFlag {
+		*algoFlag = "static"
+	} else if *quickDepth > 0 {
+		log.Fatalf("-quick-depth requires -quick")
+	}
+	if *reflectFlag != "precise" && *reflectFlag != "conservative" {
+		log.Fatalf("unknown -reflect=%s: must be precise or conservative", *reflectFlag)
+	}
+	if *colorFlag != "auto" && *colorFlag != "always" && *colorFlag != "never" {
+		log.Fatalf("unknown -color=%s: must be auto, always, or never", *colorFlag)
+	}
+	for _, f := range buildFlags {
+		name, _, _ := strings.Cut(strings.TrimLeft(f, "-"), "=")
+		switch {
+		case !strings.HasPrefix(f, "-"):
+			log.Fatalf("invalid -buildflag=%s: not a flag", f)
+		case name == "tags" && *tagsFlag != "":
+			log.Fatalf("you cannot specify both -tags and -buildflag=%s", f)
+		case reservedBuildFlags[name]:
+			log.Fatalf("invalid -buildflag=%s: the flag is set by deadcode itself", f)
+		}
+	}
+	if *maxFlag < -1 {
+		log.Fatalf("invalid -max=%d: must not be negative", *maxFlag)
+	}
+	if *parallelFlag < 1 {
+		log.Fatalf("invalid -p=%d: must be at least 1", *parallelFlag)
+	}
+	if *formatFile != "" {
+		data, err := os.ReadFile(*formatFile)
+		if err != nil {
+			log.Fatalf("-format-file: %v", err)
+		}
+		*formatFlag = string(data)
+	}
+	if *formatAll && *formatFlag == "" {
+		log.Fatalf("-format-all requires -f or -format-file")
+	}
+	if *formatFlag != "" {
+		tmpl, err := template.New("deadcode").Funcs(templateFuncs).Parse(*formatFlag)
+		if err == nil {
+			err = checkFormat(tmpl)
+		}
+		if err != nil {
+			log.Fatalf("invalid %s: %v", cond(*formatFile != "", "-format-file", "-f"), err)
+		}
+	}
+	if *baselineWrite && *baselineFlag == "" {
+		log.Fatalf("-baseline-write requires -baseline=file")
+	}
 
-	// Subtle: the -test flag causes us to analyze test variants
-	// such as "package p as compiled for p.test" or even "for q.test".
-	// This leads to multiple distinct ssa.Function instances that
-	// represent the same source declaration, and it is essentially
-	// impossible to discover this from the SSA representation
-	// (since it has lost the connection to go/packages.Package.ID).
-	//
-	// So, we de-duplicate such variants by position:
-	// if any one of them is live, we consider all of them live.
-	// (We use Position not Pos to avoid assuming that files common
-	// to packages "p" and "p [p.test]" were parsed only once.)
-	reachablePosn := make(map[token.Position]bool)
-	for fn := range res.Reachable {
-		if fn.Pos().IsValid() || fn.Name() == "init" {
-			reachablePosn[prog.Fset.Position(fn.Pos())] = true
+	// With -diff or -changed-files, determine the changed files.
+	var changed map[string]bool
+	if *diffFlag != "" {
+		var err error
+		changed, err = diffFiles(*diffFlag)
+		if err != nil {
+			log.Fatalf("-diff: %v", err)
+		}
+	} else if *changedFiles != "" {
+		var err error
+		changed, err = readChangedFiles(*changedFiles)
+		if err != nil {
+			log.Fatalf("-changed-files: %v", err)
 		}
 	}
 
-	// The -whylive=fn flag causes deadcode to explain why a function
-	// is not dead, by showing a path to it from some root.
-	if *whyLiveFlag != "" {
-		targets := make(map[*ssa.Function]bool)
-		for _, fn := range sourceFuncs {
-			if prettyName(fn, true) == *whyLiveFlag {
-				targets[fn] = true
-			}
+	// Read the baseline, unless we are about to (re)write it.
+	var baseline map[baselineKey]bool
+	if *baselineFlag != "" && !*baselineWrite {
+		var err error
+		baseline, err = readBaseline(*baselineFlag)
+		if err != nil {
+			log.Fatalf("-baseline: %v", err)
 		}
-		if len(targets) == 0 {
-			// Function is not part of the program.
-			//
-			// TODO(adonovan): improve the UX here in case
-			// of spelling or syntax mistakes. Some ideas:
-			// - a cmd/callgraph command to enumerate
-			//   available functions.
-			// - a deadcode -live flag to compute the complement.
-			// - a syntax hint: example.com/pkg.Func or (example.com/pkg.Type).Method
-			// - report the element of AllFunctions with the smallest
-			//   Levenshtein distance from *whyLiveFlag.
-			// - permit -whylive=regexp. But beware of spurious
-			//   matches (e.g. fmt.Print matches fmt.Println)
-			//   and the annoyance of having to quote parens (*T).f.
-			log.Fatalf("function %q not found in program", *whyLiveFlag)
+	}
+
+	// Read the report of an earlier run to compare with.
+	var previous []jsonPackage
+	if *compareFlag != "" {
+		var err error
+		previous, err = readReport(*compareFlag)
+		if err != nil {
+			log.Fatalf("-compare: %v", err)
 		}
+	}
 
-		// Opt: remove the unreachable ones.
-		for fn := range targets {
-			if !reachablePosn[prog.Fset.Position(fn.Pos())] {
-				delete(targets, fn)
-			}
+	// Read the coverage profile to reconcile with reachability.
+	var cov coverage
+	if *coverFlag != "" {
+		if !*reportLive {
+			log.Fatalf("-coverprofile requires -report-reachable")
+		}
+		var err error
+		cov, err = readCoverage(*coverFlag)
+		if err != nil {
+			log.Fatalf("-coverprofile: %v", err)
 		}
-		if len(targets) == 0 {
-			log.Fatalf("function %s is dead code", *whyLiveFlag)
+	}
+
+	// Read the allowlist of intentionally dead functions.
+	var allow map[string]bool
+	if *allowFlag != "" {
+		var err error
+		allow, err = readAllowlist(*allowFlag)
+		if err != nil {
+			log.Fatalf("-allow: %v", err)
 		}
+	}
 
-		res.CallGraph.DeleteSyntheticNodes() // inline synthetic wrappers (except inits)
-		root, path := pathSearch(roots, res, targets)
-		if root == nil {
-			// RTA doesn't add callgraph edges for reflective calls.
-			log.Fatalf("%s is reachable only through reflection", *whyLiveFlag)
+	// With -relative or -trim-prefix, report file names relative
+	// to the module root or the specified directory.
+	if *relativeFlag {
+		dirs, err := moduleDirs(patterns)
+		if err != nil {
+			log.Fatalf("-relative: %v", err)
+		}
+		posnDir = dirs[0]
+	} else if *trimPrefix != "" {
+		dir, err := filepath.Abs(*trimPrefix)
+		if err != nil {
+			log.Fatalf("-trim-prefix: %v", err)
 		}
-		if len(path) == 0 {
-			// No edges => one of the targets is a root.
-			// Rather than (confusingly) print nothing, make this an error.
-			log.Fatalf("%s is a root", root.Func)
+		posnDir = dir
+	}
+
+	// With -o, write the report to the named file.
+	if *outputFlag != "" {
+		f, err := os.Create(*outputFlag)
+		if err != nil {
+			log.Fatalf("-o: %v", err)
 		}
+		stdout = f // (closed on exit)
+	}
+
+	// With -timeout, abandon the analysis if it takes too long.
+	// Loading and building the program cannot be canceled, so
+	// we exit from the goroutine of the timer, after writing the
+	// profiles. The timer is stopped once the analysis is done,
+	// so that it cannot cut the output short.
+	if *timeoutFlag > 0 {
+		timeoutTimer = time.AfterFunc(*timeoutFlag, func() {
+			log.Printf("analysis exceeded -timeout=%v", *timeoutFlag)
+			atExit.run()
+			os.Exit(*timeoutExit)
+		})
+	}
+
+	// The -dot flag causes deadcode to print the call graph
+	// instead of the dead functions.
+	if *dotFlag {
+		exitIfError(deadcode.WriteDOT(stdout, config(patterns, *testFlag), *dotDepthFlag))
+		return
+	}
+
+	// The -whylive=fn flag causes deadcode to explain why a function
+	// is not dead, by showing a path to it from some root.
+	if *whyLiveFlag != "" {
+		path, err := deadcode.WhyLive(config(patterns, *testFlag), *whyLiveFlag)
+		exitIfError(err)
+		analysisDone()
 
 		// Build a list of jsonEdge records
 		// to print as -json or -f=template.
 		var edges []any
 		for _, edge := range path {
 			edges = append(edges, jsonEdge{
-				Initial:  cond(len(edges) == 0, prettyName(edge.Caller.Func, true), ""),
-				Kind:     cond(isStaticCall(edge), "static", "dynamic"),
-				Position: toJSONPosition(prog.Fset.Position(edge.Site.Pos())),
-				Callee:   prettyName(edge.Callee.Func, true),
+				Initial:  cond(len(edges) == 0, edge.Caller, ""),
+				Kind:     cond(edge.Dynamic, "dynamic", "static"),
+				Position: toJSONPosition(edge.Position),
+				Callee:   edge.Callee,
 			})
 		}
 		format := `{{if .Initial}}{{printf "%19s%s\n" "" .Initial}}{{end}}{{printf "%8s@L%.4d --> %s" .Kind .Position.Line .Callee}}`
@@ -272,141 +609,952 @@ func main() {
 		return
 	}
 
-	// Group unreachable functions by package path.
-	byPkgPath := make(map[string]map[*ssa.Function]bool)
-	for _, fn := range sourceFuncs {
-		posn := prog.Fset.Position(fn.Pos())
+	// The -if-removed=fn flag causes deadcode to report the functions
+	// that would become dead if the named function were deleted.
+	// Finding them is not a failure.
+	if *ifRemovedFlag != "" {
+		found, err := deadcode.IfRemoved(config(patterns, *testFlag), *ifRemovedFlag)
+		exitIfError(err)
+		analysisDone()
+		report(found, changed, baseline, allow, previous, cov)
+		return
+	}
 
-		if !reachablePosn[posn] {
-			reachablePosn[posn] = true // suppress dups with same pos
+	// The -explain-package flag causes deadcode to show whether each
+	// function of a package is reachable, and how, to explain why
+	// the package has less dead code than expected.
+	if *explainFlag != "" {
+		statuses, err := deadcode.ExplainPackage(config(patterns, *testFlag), *explainFlag)
+		exitIfError(err)
+		analysisDone()
+
+		var objects []any
+		for _, status := range statuses {
+			s := jsonFuncStatus{
+				Name:      status.Name,
+				Position:  toJSONPosition(status.Position),
+				Reachable: status.Reachable,
+				Root:      status.Root,
+				Caller:    status.Caller,
+			}
+			if status.CallSite.IsValid() {
+				s.CallSite = toJSONPosition(status.CallSite)
+			}
+			objects = append(objects, s)
+		}
+		// "a/b/c.go:1:2: reachable func: f (called by a/b.g at a/b/c.go:3:4)"
+		format := `{{printf "%s: " .Position}}{{if .Reachable}}reachable{{else}}unreachable{{end}} func: {{.Name}}` +
+			`{{if .Root}} (root){{else if .Caller}}{{printf " (called by %s at %s)" .Caller .CallSite}}{{else if .Reachable}} (through reflection){{end}}`
+		if *formatFlag != "" {
+			format = *formatFlag
+		}
+		printObjects(format, objects)
+		return
+	}
 
-			pkgpath := fn.Pkg.Pkg.Path()
-			m, ok := byPkgPath[pkgpath]
-			if !ok {
-				m = make(map[*ssa.Function]bool)
-				byPkgPath[pkgpath] = m
+	// The -binary flag causes deadcode to cross-check its results
+	// against the functions that the linker kept in an executable:
+	// it shows the dead functions that are present in it, and the
+	// reachable ones that are absent.
+	if *binaryFlag != "" {
+		symbols, err := readSymbols(*binaryFlag)
+		if err != nil {
+			log.Fatalf("-binary: %v", err)
+		}
+		cfg := config(patterns, *testFlag)
+		dead, err := deadcode.Find(cfg)
+		exitIfError(err)
+		cfg.Reachable = true
+		live, err := deadcode.Find(cfg)
+		exitIfError(err)
+		analysisDone()
+
+		// "a/b/c.go:1:2: unreachable func in binary: T.f"
+		format := `{{printf "%s: " .Position}}{{if .Dead}}unreachable func in binary{{else}}reachable func not in binary{{end}}: {{.Name}}`
+		if *formatFlag != "" {
+			format = *formatFlag
+		}
+		printObjects(format, crossCheck(dead, live, symbols))
+		return
+	}
+
+	// The -print-roots flag causes deadcode to print the roots of
+	// the analysis, to help explain why functions are live or dead.
+	if *rootsFlag {
+		roots, err := deadcode.Roots(config(patterns, *testFlag))
+		exitIfError(err)
+		analysisDone()
+
+		var objects []any
+		for _, root := range roots {
+			r := jsonRoot{Name: root.Name}
+			if root.Position.IsValid() {
+				r.Position = toJSONPosition(root.Position)
 			}
-			m[fn] = true
+			objects = append(objects, r)
+		}
+		// "example.com/cmd.main (a/b/c.go:1:2)"
+		format := `{{.Name}}{{if .Position.File}}{{printf " (%s)" .Position}}{{end}}`
+		if *formatFlag != "" {
+			format = *formatFlag
 		}
+		printObjects(format, objects)
+		return
 	}
 
-	// Build array of jsonPackage objects.
-	var packages []any
-	pkgpaths := keys(byPkgPath)
-	sort.Strings(pkgpaths)
-	for _, pkgpath := range pkgpaths {
-		if !filter.MatchString(pkgpath) {
+	// The -reachable-from flag causes deadcode to show, for each
+	// reachable package, the main packages whose executables reach
+	// it, to inform the deletion of commands and their exclusive
+	// dependencies.
+	if *reachFlag {
+		reach, err := deadcode.ReachableFrom(config(patterns, *testFlag))
+		exitIfError(err)
+		analysisDone()
+
+		filters, excludes := packageFilters(reach.Modules)
+		var pkgs []any
+		for _, pkg := range reach.Packages {
+			if selected(filters, excludes, pkg.Path) {
+				pkgs = append(pkgs, jsonReach{Path: pkg.Path, Mains: pkg.Mains})
+			}
+		}
+		// "a/b\n\tcmd/x\n\tcmd/y"
+		format := `{{.Path}}{{range .Mains}}{{printf "\n\t%s" .}}{{end}}`
+		if *formatFlag != "" {
+			format = *formatFlag
+		}
+		printObjects(format, pkgs)
+		return
+	}
+
+	// With -watch, report the dead code again
+	// whenever the program changes, until interrupted.
+	if *watchFlag {
+		watch(patterns, func() {
+			found, err := findDead(patterns, platforms, diffTags)
+			if err != nil {
+				printError(err)
+				return
+			}
+			report(found, changed, baseline, allow, previous, cov)
+		})
+	}
+
+	found, err := findDead(patterns, platforms, diffTags)
+	exitIfError(err)
+	analysisDone()
+	n := report(found, changed, baseline, allow, previous, cov)
+
+	// With -report-reachable or -dynamic-only, listing the functions
+	// is not a failure, unless -max or -set-exit-status asks for a gate.
+	if (*reportLive || *dynamicOnly) && *maxFlag < 0 && !*exitFlag {
+		return
+	}
+
+	// With -max=n, tolerate up to n dead functions,
+	// so that a gate can ratchet down a legacy count.
+	if *maxFlag >= 0 {
+		if n <= *maxFlag {
+			return
+		}
+		log.Printf("%d %s functions exceed -max=%d by %d",
+			n, reportedAs("dead"), *maxFlag, n-*maxFlag)
+	}
+	if n > 0 {
+		atExit.run()
+		if *exitFlag {
+			os.Exit(3)
+		}
+		os.Exit(1)
+	}
+}
+
+// findDeadIn returns the dead code of the packages denoted by the
+// patterns, or with -include-tests-only, the functions that only
+// their tests keep alive, in the specified build configuration.
+func findDeadIn(patterns []string, bc buildConfig) (*deadcode.Findings, error) {
+	found, err := find(patterns, *testFlag, bc)
+	if err != nil {
+		return nil, err
+	}
+
+	// With -include-tests-only, find the dead code again, this time
+	// including tests, and report only the functions that the tests
+	// alone keep alive.
+	if *testsOnlyFlag {
+		withTests, err := find(patterns, true, bc)
+		if err != nil {
+			return nil, err
+		}
+		deadWithTests := make(map[token.Position]bool)
+		for _, pkg := range withTests.Packages {
+			for _, fn := range pkg.Funcs {
+				deadWithTests[fn.Position] = true
+			}
+		}
+		for i := range found.Packages {
+			pkg := &found.Packages[i]
+			var testsOnly []deadcode.Function
+			for _, fn := range pkg.Funcs {
+				if !deadWithTests[fn.Position] {
+					testsOnly = append(testsOnly, fn)
+				}
+			}
+			pkg.Funcs = testsOnly
+		}
+	}
+	return found, nil
+}
+
+// report prints the dead code that passes the filters in the format
+// selected by the flags, and returns the number of functions reported,
+// as counted by -count.
+func report(found *deadcode.Findings, changed map[string]bool, baseline map[baselineKey]bool, allow map[string]bool, previous []jsonPackage, cov coverage) int {
+	// The functions reported are unreachable, or
+	// with -dynamic-only, called only dynamically.
+	adjective := reportedAs("unreachable")
+
+	// With -watch, the files may have changed since the last report.
+	sourceLines = make(map[string][]string)
+
+	for _, warning := range found.Warnings {
+		warnf("%s", warning)
+	}
+
+	filters, excludes := packageFilters(found.Modules)
+
+	// Warn if the user's filters match none of the analyzed packages,
+	// as this is more likely a mistake than a clean bill of health.
+	if len(filterFlag) > 0 || len(filterGlob) > 0 {
+		pkgpaths := keys(found.NumFuncs)
+		if len(pkgpaths) > 0 && !containsFunc(pkgpaths, func(pkgpath string) bool { return matchAny(filters, pkgpath) }) {
+			sort.Strings(pkgpaths)
+			examples := pkgpaths
+			if len(examples) > 3 {
+				examples = examples[:3]
+			}
+			warnf("no package matches -filter or -filter-glob; the %d analyzed packages include %s",
+				len(pkgpaths), strings.Join(examples, ", "))
+		}
+	}
+
+	// Group unreachable functions by package path,
+	// skipping packages that don't match the filters.
+	byPkgPath := make(map[string]*jsonPackage)
+	ngenerated := 0 // number of dead functions omitted from generated files
+	ndead := 0      // number of dead functions, for -stats
+	for _, pkg := range found.Packages {
+		if !selected(filters, excludes, pkg.Path) {
+			continue
+		}
+		// With -exclude-main, skip the main packages, which are
+		// often thin wrappers around the interesting libraries.
+		if *excludeMain && pkg.Name == "main" {
 			continue
 		}
+		for _, fn := range pkg.Funcs {
+			if fn.Kind == "func" {
+				ndead++
+			}
+			f := toJSONFunction(pkg.Path, fn)
 
-		m := byPkgPath[pkgpath]
+			// With -diff or -changed-files, skip functions
+			// declared in unchanged files.
+			if changed != nil && !changed[fn.Position.Filename] {
+				continue
+			}
+
+			// Skip functions annotated //deadcode:ignore.
+			if fn.Ignored {
+				if *showIgnored {
+					log.Printf("%s: ignored %s %s: %s", f.Position, adjective, f.Kind, f.Name)
+				}
+				continue
+			}
 
-		// Print functions that appear within the same file in
-		// declaration order. This tends to keep related
-		// methods such as (T).Marshal and (*T).Unmarshal
-		// together better than sorting.
-		fns := keys(m)
-		sort.Slice(fns, func(i, j int) bool {
-			xposn := prog.Fset.Position(fns[i].Pos())
-			yposn := prog.Fset.Position(fns[j].Pos())
-			if xposn.Filename != yposn.Filename {
-				return xposn.Filename < yposn.Filename
+			// With -no-vendor (the default), skip functions in
+			// vendored packages, which are not ours to fix.
+			if *noVendorFlag && isVendored(fn.Position.Filename) {
+				continue
 			}
-			return xposn.Line < yposn.Line
-		})
 
-		var functions []jsonFunction
-		for _, fn := range fns {
-			posn := prog.Fset.Position(fn.Pos())
+			// With -exclude-test-files, skip functions declared
+			// in test files, such as unused test helpers.
+			if *noTestFiles && strings.HasSuffix(fn.Position.Filename, "_test.go") {
+				continue
+			}
+
+			// With -skip-files, skip functions declared in
+			// frozen files, such as legacy code kept on purpose.
+			if matchAny(skipFileREs, fn.Position.Filename) {
+				continue
+			}
+
+			// With -no-init, skip package initializer functions,
+			// which are named init#1, init#2, and so on.
+			if *noInitFlag && f.Kind == "func" && isInit(f.Name) {
+				continue
+			}
+
+			// With -keep-exported, skip the package's public API.
+			if *keepExported && isPublic(f.Name) {
+				continue
+			}
+
+			// With -unexported-only, skip all exported names,
+			// even methods of unexported types.
+			if *unexpOnly && f.Exported {
+				continue
+			}
+
+			// With -exclude-func, skip functions named by
+			// convention, such as mocks.
+			if matchAny(excludeFuncs, f.Name) {
+				continue
+			}
+
+			// With -coverprofile, skip functions that were executed,
+			// and those whose coverage is unknown.
+			if cov != nil {
+				if executed, known := cov.executed(pkg.Path, f); executed || !known {
+					continue
+				}
+			}
+
+			// With -min-lines, skip functions too short to matter.
+			if f.Kind == "func" && f.Lines > 0 && f.Lines < *minLinesFlag {
+				continue
+			}
 
 			// Without -generated, skip functions declared in
 			// generated Go files.
 			// (Functions called by them may still be reported.)
-			gen := generated[posn.Filename]
-			if gen && !*generatedFlag {
+			if f.Generated && !*generatedFlag {
+				ngenerated++
 				continue
 			}
 
-			functions = append(functions, jsonFunction{
-				Name:      prettyName(fn, false),
-				Position:  toJSONPosition(posn),
-				Generated: gen,
-			})
+			// Skip functions recorded in the baseline,
+			// and those listed in the allowlist.
+			if baseline[baselineKey{pkg.Path, f.Name}] || allow[pkg.Path+"."+f.Name] {
+				continue
+			}
+
+			p, ok := byPkgPath[pkg.Path]
+			if !ok {
+				p = &jsonPackage{Name: pkg.Name, Path: pkg.Path}
+				byPkgPath[pkg.Path] = p
+			}
+			p.Funcs = append(p.Funcs, f)
 		}
-		if len(functions) > 0 {
-			packages = append(packages, jsonPackage{
-				Name:  fns[0].Pkg.Pkg.Name(),
-				Path:  pkgpath,
-				Funcs: functions,
+	}
+
+	// Build array of jsonPackage objects.
+	var packages []any
+	nreported := 0
+	pkgpaths := keys(byPkgPath)
+	sort.Strings(pkgpaths)
+	for _, pkgpath := range pkgpaths {
+		p := byPkgPath[pkgpath]
+
+		// By default, print functions that appear within the
+		// same file in declaration order. This tends to keep
+		// related methods such as (T).Marshal and (*T).Unmarshal
+		// together better than sorting by name.
+		// Functions at the same position, such as the variants of
+		// a function in generated code, are ordered by kind and
+		// name, so that the output is deterministic.
+		sort.Slice(p.Funcs, func(i, j int) bool {
+			x, y := p.Funcs[i], p.Funcs[j]
+			if x.Position.File != y.Position.File {
+				return x.Position.File < y.Position.File
+			}
+			if x.Position.Line != y.Position.Line {
+				return x.Position.Line < y.Position.Line
+			}
+			if x.Position.Col != y.Position.Col {
+				return x.Position.Col < y.Position.Col
+			}
+			if x.Kind != y.Kind {
+				return x.Kind < y.Kind
+			}
+			return x.Name < y.Name
+		})
+		switch *sortFlag {
+		case "name":
+			sort.SliceStable(p.Funcs, func(i, j int) bool {
+				return p.Funcs[i].Name < p.Funcs[j].Name
+			})
+		case "size":
+			// Largest first, to prioritize cleanup.
+			sort.SliceStable(p.Funcs, func(i, j int) bool {
+				return p.Funcs[i].Lines > p.Funcs[j].Lines
 			})
 		}
+
+		// With -dedup-by=name, coalesce functions of the same
+		// name, such as variants of one function that appear at
+		// different positions in different test executables.
+		// The first is the representative.
+		if *dedupFlag == "name" {
+			index := make(map[string]int) // maps kind and name to index in funcs
+			funcs := p.Funcs[:0]
+			for _, f := range p.Funcs {
+				key := f.Kind + " " + f.Name
+				if i, ok := index[key]; ok {
+					funcs[i].OtherPosns = append(funcs[i].OtherPosns, f.Position.String())
+				} else {
+					index[key] = len(funcs)
+					funcs = append(funcs, f)
+				}
+			}
+			p.Funcs = funcs
+		}
+
+		// With -group=file, also group the package's
+		// functions by file, preserving their order.
+		if *groupFlag == "file" {
+			index := make(map[string]int) // maps file name to index in p.Files
+			for _, f := range p.Funcs {
+				i, ok := index[f.Position.File]
+				if !ok {
+					i = len(p.Files)
+					index[f.Position.File] = i
+					p.Files = append(p.Files, jsonFile{Name: f.Position.File})
+				}
+				p.Files[i].Funcs = append(p.Files[i].Funcs, f)
+			}
+		}
+
+		// With -group=kind, also group the package's functions
+		// into sections, such as functions and methods,
+		// preserving their order.
+		if *groupFlag == "kind" {
+			for _, name := range sectionNames {
+				var funcs []jsonFunction
+				for _, f := range p.Funcs {
+					if sectionOf(f) == name {
+						funcs = append(funcs, f)
+					}
+				}
+				if funcs != nil {
+					p.Sections = append(p.Sections, jsonSection{Name: name, Funcs: funcs})
+				}
+			}
+		}
+
+		nreported += len(p.Funcs)
+		if *jsonlFlag && !*baselineWrite {
+			// Stream each package as soon as it is complete.
+			printObjects("", []any{*p})
+		} else {
+			packages = append(packages, *p)
+		}
+	}
+
+	// With -baseline-write, record the dead functions
+	// instead of reporting them.
+	if *baselineWrite {
+		if err := writeBaseline(*baselineFlag, packages); err != nil {
+			log.Fatalf("-baseline-write: %v", err)
+		}
+		return 0
+	}
+
+	// With -compare, report the changes since
+	// the earlier run instead of the dead functions.
+	if *compareFlag != "" {
+		printComparison(previous, packages)
+		return nreported
+	}
+
+	// With -color, the default formats show package paths in bold,
+	// function names in color, and a dim marker after functions
+	// in generated files. Otherwise, these strings are empty.
+	var bold, name, generated, reset string
+	if useColor() {
+		const esc = "\x1b["
+		bold, name, reset = esc+"1m", esc+"36m", esc+"0m"
+		generated = `{{if .Generated}}` + esc + `2m (generated)` + reset + `{{end}}`
+	}
+
+	// With -links, the default formats show positions as hyperlinks.
+	position := `{{.Position}}`
+	lineCol := `{{.Position.Line}}:{{.Position.Col}}`
+	if useLinks() {
+		position = `{{link .Position}}`
+		lineCol = `{{link .Position (printf "%d:%d" .Position.Line .Position.Col)}}`
+	}
+
+	// With -no-dedup, the default formats show the package
+	// variant of each function.
+	var variant string
+	if *noDedup {
+		variant = `{{if .Variant}}{{printf " (%s)" .Variant}}{{end}}`
+	}
+
+	// With -context, the line-oriented formats
+	// show the first lines of each function.
+	var context string
+	if *contextFlag > 0 {
+		context = fmt.Sprintf(`{{source .Position %d}}`, *contextFlag)
 	}
 
 	// Default line-oriented format: "a/b/c.go:1:2: unreachable func: T.f"
-	format := `{{range .Funcs}}{{printf "%s: unreachable func: %s\n" .Position .Name}}{{end}}`
+	// (or "dynamic-only func" with -dynamic-only, and
+	// "reachable func" with -report-reachable)
+	format := `{{range .Funcs}}` + position + `{{printf ": ` + adjective + ` %s: " .Kind}}` + name + `{{.Name}}` + reset + generated + variant + `{{println}}` + context + `{{end}}`
+	if *groupFlag == "file" {
+		// "a/b\n\ta/b/c.go\n\t\t1:2: func T.f\n\n"
+		format = bold + `{{.Path}}` + reset + `{{println}}{{range .Files}}{{printf "\t%s\n" .Name}}{{range .Funcs}}{{printf "\t\t"}}` + lineCol + `{{printf ": %s " .Kind}}` + name + `{{.Name}}` + reset + generated + variant + `{{println}}{{end}}{{end}}{{println}}`
+	} else if *groupFlag == "kind" {
+		// "a/b\n\tfunctions\n\t\ta/b/c.go:1:2: f\n\tmethods\n\t\ta/b/c.go:3:4: T.m\n\n"
+		format = bold + `{{.Path}}` + reset + `{{println}}{{range .Sections}}{{printf "\t%s\n" .Name}}{{range .Funcs}}{{printf "\t\t"}}` + position + `{{printf ": "}}` + name + `{{.Name}}` + reset + generated + variant + `{{println}}{{end}}{{end}}{{println}}`
+	} else if *lineCountFlag {
+		// "a/b/c.go:1:2: unreachable func: T.f (3 lines)"
+		format = `{{range .Funcs}}` + position + `{{printf ": ` + adjective + ` %s: " .Kind}}` + name + `{{.Name}}` + reset + generated + variant + `{{if .Lines}}{{printf " (%d lines)" .Lines}}{{end}}{{println}}` + context + `{{end}}`
+	}
 	if *formatFlag != "" {
 		format = *formatFlag
 	}
-	printObjects(format, packages)
-	if len(packages) > 0 {
+	if *countFlag {
+		printCount(packages, ngenerated)
+	} else if *dirSummary {
+		printDirSummary(packages)
+	} else if *sarifFlag {
+		printSARIF(packages)
+	} else if *csvFlag {
+		printCSV(packages)
+	} else if *jsonFlag && !*jsonLegacy {
+		printJSONReport(packages)
+	} else {
+		printObjects(format, packages)
+	}
+
+	// With -stats, summarize the functions of the filtered
+	// packages, including those not reported for other reasons.
+	if *statsFlag {
+		ntotal := 0
+		for pkgpath, n := range found.NumFuncs {
+			if selected(filters, excludes, pkgpath) {
+				ntotal += n
+			}
+		}
+		// Keep machine-readable output parseable.
+		var w io.Writer = stdout
+		if *jsonFlag || *jsonlFlag || *sarifFlag || *csvFlag {
+			w = os.Stderr
+		}
+		printStats(w, ntotal, ndead)
+	}
+
+	return nreported
+}
+
+// packageFilters returns the regular expressions that select the
+// packages to report, according to the -filter, -filter-glob, and
+// -exclude flags, given the modules of the initial packages.
+func packageFilters(modules []string) (filters, excludes []*regexp.Regexp) {
+	// If -filter and -filter-glob are unset, use the modules
+	// of the initial packages (if available).
+	filterExprs := filterFlag
+	if len(filterFlag) == 0 && len(filterGlob) == 0 {
+		filterExprs = stringList{"<module>"}
+	}
+	for _, expr := range filterExprs {
+		if expr == "<module>" {
+			if len(modules) == 0 {
+				filters = append(filters, regexp.MustCompile("")) // match any
+			}
+			for _, mod := range modules {
+				filters = append(filters, regexp.MustCompile("^"+regexp.QuoteMeta(mod)+"\\b"))
+			}
+			continue
+		}
+		filter, err := regexp.Compile(expr)
+		if err != nil {
+			log.Fatalf("-filter: %v", err)
+		}
+		filters = append(filters, filter)
+	}
+	for _, glob := range filterGlob {
+		filters = append(filters, regexp.MustCompile(globRegexp(glob)))
+	}
+	for _, expr := range excludeFlag {
+		exclude, err := regexp.Compile(expr)
+		if err != nil {
+			log.Fatalf("-exclude: %v", err)
+		}
+		excludes = append(excludes, exclude)
+	}
+	return filters, excludes
+}
+
+// onlyPkgs is the set of package paths named by -only, or nil if it is unset.
+var onlyPkgs map[string]bool
+
+// excludeFuncs holds the compiled -exclude-func patterns.
+var excludeFuncs []*regexp.Regexp
+
+// skipFileREs holds the compiled -skip-files patterns.
+var skipFileREs []*regexp.Regexp
+
+// selected reports whether the package with the specified path is
+// to be reported: it must match one of the filters and none of the
+// excludes, and if -only is set, be named by it.
+func selected(filters, excludes []*regexp.Regexp, pkgpath string) bool {
+	return matchAny(filters, pkgpath) && !matchAny(excludes, pkgpath) &&
+		(onlyPkgs == nil || onlyPkgs[pkgpath])
+}
+
+// find returns the findings for the packages denoted by the
+// patterns, and their tests if requested, in the specified build
+// configuration, reusing the findings of an earlier run over the same
+// inputs if -ssa-cache is set.
+func find(patterns []string, tests bool, bc buildConfig) (*deadcode.Findings, error) {
+	cacheFile := ""
+	if *ssaCacheFlag != "" {
+		var err error
+		cacheFile, err = cacheFileName(*ssaCacheFlag, patterns, tests, bc)
+		if err != nil {
+			log.Fatalf("-ssa-cache: %v", err)
+		}
+		if found := readCache(cacheFile); found != nil {
+			logf("using cached findings in %s", cacheFile)
+			return found, nil
+		}
+	}
+	cfg := config(patterns, tests)
+	cfg.Tags, cfg.Env = bc.tags, bc.env
+	found, err := deadcode.Find(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cacheFile != "" {
+		if err := writeCache(cacheFile, found); err != nil {
+			log.Printf("-ssa-cache: %v", err)
+		}
+	}
+	return found, nil
+}
+
+// config returns the configuration for analyzing the packages denoted
+// by the patterns, and their tests if requested, according to the flags.
+func config(patterns []string, tests bool) deadcode.Config {
+	cfg := deadcode.Config{
+		Patterns:               patterns,
+		Tests:                  tests,
+		Tags:                   *tagsFlag,
+		BuildFlags:             buildFlags,
+		Entry:                  entryFlag,
+		RootRegexp:             *rootExpr,
+		Library:                *libFlag,
+		GeneratedRegexp:        *generatedExpr,
+		IgnoreGeneratedCallers: *genCallers,
+		Algorithm:              *algoFlag,
+		MaxDepth:               *quickDepth,
+		Reflection:             *reflectFlag,
+		Methods:                *methodsFlag,
+		DynamicOnly:            *dynamicOnly,
+		Reachable:              *reportLive,
+		NoDedup:                *noDedup,
+		Vars:                   *varsFlag,
+		Fields:                 *fieldsFlag,
+		Types:                  *typesFlag,
+		SwitchCases:            *casesFlag,
+		InterfaceMethods:       *ifaceFlag,
+		BlankImports:           *blankFlag,
+		Parallel:               *parallelFlag,
+	}
+	if *verboseFlag {
+		cfg.Logf = log.Printf
+	}
+	return cfg
+}
+
+// atExit holds the functions to call before the command exits, such
+// as to write the profiles.
+var atExit cleanups
+
+// cleanups is a list of functions to call, in reverse order, at most once.
+type cleanups struct {
+	once  sync.Once
+	funcs []func()
+}
+
+func (c *cleanups) add(f func()) { c.funcs = append(c.funcs, f) }
+
+func (c *cleanups) run() {
+	c.once.Do(func() {
+		for i := len(c.funcs) - 1; i >= 0; i-- {
+			c.funcs[i]()
+		}
+	})
+}
+
+// timeoutTimer, if not nil, exits the command when -timeout elapses.
+var timeoutTimer *time.Timer
+
+// analysisDone stops the -timeout timer, if any, since the analysis
+// is done: the time taken to print its results is not bounded. If the
+// timer has fired already, it waits for the command to exit.
+func analysisDone() {
+	if timeoutTimer != nil && !timeoutTimer.Stop() {
+		select {}
+	}
+}
+
+// exitIfError reports the error of the analysis, if any, and exits.
+func exitIfError(err error) {
+	if err != nil {
+		printError(err)
+		atExit.run()
 		os.Exit(1)
 	}
 }
 
-// prettyName is a fork of Function.String designed to reduce
-// go/ssa's fussy punctuation symbols, e.g. "(*pkg.T).F" -> "pkg.T.F".
-//
-// It only works for functions that remain after
-// callgraph.Graph.DeleteSyntheticNodes: source-level named functions
-// and methods, their anonymous functions, and synthetic package
-// initializers.
-func prettyName(fn *ssa.Function, qualified bool) string {
-	var buf strings.Builder
+// printError reports the error of the analysis.
+func printError(err error) {
+	if loadErr, ok := err.(*deadcode.LoadError); ok {
+		loadFailed(loadErr)
+	} else {
+		log.Print(err)
+	}
+}
 
-	// optional package qualifier
-	if qualified && fn.Pkg != nil {
-		fmt.Fprintf(&buf, "%s.", fn.Pkg.Pkg.Path())
+// loadFailed reports that the program could not be loaded, along with
+// the errors of its packages, if any. With -json, it prints a
+// jsonLoadError to the standard output, so that clients need not
+// parse the standard error to distinguish failure from success.
+func loadFailed(loadErr *deadcode.LoadError) {
+	if !*jsonFlag {
+		for _, p := range loadErr.Packages {
+			for _, err := range p.Errors {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		log.Print(loadErr.Msg)
+		return
 	}
+	errs := []jsonPackageErrors{} // "[]", not "null"
+	for _, p := range loadErr.Packages {
+		errs = append(errs, jsonPackageErrors{Path: p.Path, Errors: p.Errors})
+	}
+	out, err := marshalJSON(jsonLoadError{Error: loadErr.Msg, Packages: errs})
+	if err != nil {
+		log.Fatalf("internal error: %v", err)
+	}
+	stdout.Write(out)
+}
 
-	var format func(*ssa.Function)
-	format = func(fn *ssa.Function) {
-		// anonymous?
-		if fn.Parent() != nil {
-			format(fn.Parent())
-			i := index(fn.Parent().AnonFuncs, fn)
-			fmt.Fprintf(&buf, "$%d", i+1)
-			return
+// reservedBuildFlags are the flags of "go list" that go/packages
+// sets according to the mode and the -test flag, and that the
+// -buildflag flag therefore must not override.
+var reservedBuildFlags = map[string]bool{
+	"compiled": true,
+	"deps":     true,
+	"e":        true,
+	"export":   true,
+	"f":        true,
+	"find":     true,
+	"json":     true,
+	"m":        true,
+	"test":     true,
+}
+
+// warnf logs a warning, unless -q is set.
+func warnf(format string, args ...any) {
+	if !*quietFlag {
+		log.Printf("warning: "+format, args...)
+	}
+}
+
+// logf logs a progress message, if -v is set.
+func logf(format string, args ...any) {
+	if *verboseFlag {
+		log.Printf(format, args...)
+	}
+}
+
+// loadConfig returns the configuration for loading the packages,
+// and their tests if requested, in the specified mode, with the
+// specified build tags. (The analysis itself uses the same build
+// flags.)
+func loadConfig(mode packages.LoadMode, tests bool, tags string) *packages.Config {
+	return &packages.Config{
+		BuildFlags: append([]string{"-tags=" + tags}, buildFlags...),
+		Mode:       mode,
+		Tests:      tests,
+	}
+}
+
+// moduleDirs returns the root directories of the modules of the
+// packages denoted by the patterns, or the current directory if they
+// do not belong to modules.
+func moduleDirs(patterns []string) ([]string, error) {
+	initial, err := packages.Load(loadConfig(packages.NeedName|packages.NeedModule, *testFlag, *tagsFlag), patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, p := range initial {
+		if p.Module != nil && p.Module.Dir != "" && !containsFunc(dirs, func(dir string) bool { return dir == p.Module.Dir }) {
+			dirs = append(dirs, p.Module.Dir)
 		}
+	}
+	if len(dirs) == 0 {
+		dirs = []string{cwd}
+	}
+	return dirs, nil
+}
 
-		// method receiver?
-		if recv := fn.Signature.Recv(); recv != nil {
-			_, named := typesinternal.ReceiverNamed(recv)
-			buf.WriteString(named.Obj().Name())
-			buf.WriteByte('.')
+// readEntries returns the function names listed in the named file,
+// which holds a JSON array of strings, such as a list of the entry
+// points of plugins generated by a build tool.
+func readEntries(filename string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return names, nil
+}
+
+// readPatterns returns the package patterns listed in the named file,
+// or the standard input if the name is "-". Each non-blank line that
+// does not start with '#' is a pattern.
+func readPatterns(filename string) ([]string, error) {
+	var in io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	var patterns []string
+	scan := bufio.NewScanner(in)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
 		}
+	}
+	return patterns, scan.Err()
+}
+
+// templateFuncs are the functions available to -f templates, in
+// addition to the standard ones.
+var templateFuncs = template.FuncMap{
+	"base":   filepath.Base, // "a/b/c.go" -> "c.go"
+	"dir":    filepath.Dir,  // "a/b/c.go" -> "a/b"
+	"short":  path.Base,     // "example.com/a/b" -> "b"
+	"source": source,        // "\t12\tfunc f() {\n..."
+	"add":    add,           // 1 2 -> 3
+	"link":   link,          // "a/b/c.go:1:2", as a terminal hyperlink
+}
 
-		// function/method name
-		buf.WriteString(fn.Name())
+func add(x, y int) int { return x + y }
+
+// sourceLines caches the lines of each file read by source,
+// during one report.
+var sourceLines = make(map[string][]string)
+
+// source returns the n lines of source starting at the line of the
+// position, with trailing white space removed, each preceded by a tab
+// and its line number and followed by a newline.
+func source(posn jsonPosition, n int) (string, error) {
+	filename := posn.File
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(posnDir, filename)
 	}
-	format(fn)
+	lines, ok := sourceLines[filename]
+	if !ok {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+		lines = strings.Split(string(data), "\n")
+		sourceLines[filename] = lines
+	}
+	var buf strings.Builder
+	for line := posn.Line; line < posn.Line+n && line <= len(lines); line++ {
+		fmt.Fprintf(&buf, "\t%d\t%s\n", line, strings.TrimRight(lines[line-1], " \t\r"))
+	}
+	return buf.String(), nil
+}
 
-	return buf.String()
+// checkFormat executes the -f template on a sample record, with zero
+// values, of the type printed in the selected mode, so that errors
+// such as a misspelled field name are reported before the analysis,
+// which may take minutes, rather than after it.
+func checkFormat(tmpl *template.Template) error {
+	var sample any
+	switch {
+	case *whyLiveFlag != "":
+		sample = jsonEdge{}
+	case *explainFlag != "":
+		sample = jsonFuncStatus{}
+	case *binaryFlag != "":
+		sample = jsonBinaryFunc{}
+	case *rootsFlag:
+		sample = jsonRoot{}
+	case *reachFlag:
+		sample = jsonReach{Mains: []string{""}}
+	default:
+		// Give each list one element, so that
+		// the template's ranges are checked too.
+		funcs := []jsonFunction{{}}
+		sample = jsonPackage{
+			Funcs:    funcs,
+			Files:    []jsonFile{{Funcs: funcs}},
+			Sections: []jsonSection{{Funcs: funcs}},
+		}
+	}
+	if *formatAll {
+		sample = []any{sample}
+	}
+	// The zero position has no source to read.
+	tmpl.Funcs(template.FuncMap{"source": func(jsonPosition, int) string { return "" }})
+	return tmpl.Execute(io.Discard, sample)
 }
 
 // printObjects formats an array of objects, either as JSON or using a
 // template, following the manner of 'go list (-json|-f=template)'.
+// With -jsonl, each object is printed as JSON on a single line.
 func printObjects(format string, objects []any) {
+	if *jsonlFlag {
+		enc := json.NewEncoder(stdout)
+		for _, object := range objects {
+			if err := enc.Encode(object); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
 	if *jsonFlag {
-		out, err := json.MarshalIndent(objects, "", "\t")
+		out, err := marshalJSON(objects)
 		if err != nil {
 			log.Fatalf("internal error: %v", err)
 		}
-		os.Stdout.Write(out)
+		stdout.Write(out)
 		return
 	}
 
 	// -f=template. Parse can't fail: we checked it earlier.
-	tmpl := template.Must(template.New("deadcode").Parse(format))
+	// With -format-all, the template is executed once,
+	// on the list of all records.
+	tmpl := template.Must(template.New("deadcode").Funcs(templateFuncs).Parse(format))
+	if *formatAll && *formatFlag != "" {
+		if objects == nil {
+			objects = []any{} // so that len works
+		}
+		objects = []any{objects}
+	}
 	for _, object := range objects {
 		var buf bytes.Buffer
 		if err := tmpl.Execute(&buf, object); err != nil {
@@ -415,156 +1563,326 @@ func printObjects(format string, objects []any) {
 		if n := buf.Len(); n == 0 || buf.Bytes()[n-1] != '\n' {
 			buf.WriteByte('\n')
 		}
-		os.Stdout.Write(buf.Bytes())
+		stdout.Write(buf.Bytes())
 	}
 }
 
-// TODO(adonovan): use go1.21's ast.IsGenerated.
-
-// isGenerated reports whether the file was generated by a program,
-// not handwritten, by detecting the special comment described
-// at https://go.dev/s/generatedcode.
-//
-// The syntax tree must have been parsed with the ParseComments flag.
-// Example:
+// schemaVersion is the version of the schema of the -json output.
+// Increment it whenever the shape of the records changes:
 //
-//	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.PackageClauseOnly)
-//	if err != nil { ... }
-//	gen := ast.IsGenerated(f)
-func isGenerated(file *ast.File) bool {
-	_, ok := generator(file)
-	return ok
-}
-
-func generator(file *ast.File) (string, bool) {
-	for _, group := range file.Comments {
-		for _, comment := range group.List {
-			if comment.Pos() > file.Package {
-				break // after package declaration
-			}
-			// opt: check Contains first to avoid unnecessary array allocation in Split.
-			const prefix = "// Code generated "
-			if strings.Contains(comment.Text, prefix) {
-				for _, line := range strings.Split(comment.Text, "\n") {
-					if rest, ok := strings.CutPrefix(line, prefix); ok {
-						if gen, ok := strings.CutSuffix(rest, " DO NOT EDIT."); ok {
-							return gen, true
-						}
-					}
-				}
-			}
+//	2: Function.IsMethod and Package.Sections
+//	3: Function.ID
+//	4: Function.Recv and Function.Pkg
+//	5: Function.TypeArgs
+//	6: Function.Variant
+const schemaVersion = 6
+
+// printJSONReport prints the packages as a jsonReport.
+func printJSONReport(packages []any) {
+	if packages == nil {
+		packages = []any{} // "[]", not "null"
+	}
+	out, err := marshalJSON(jsonReport{schemaVersion, packages})
+	if err != nil {
+		log.Fatalf("internal error: %v", err)
+	}
+	stdout.Write(out)
+}
+
+// marshalJSON returns the JSON encoding of v, for the -json flag:
+// indented for legibility, or with -json-compact, on a single line.
+func marshalJSON(v any) ([]byte, error) {
+	if *jsonCompact {
+		data, err := json.Marshal(v)
+		return append(data, '\n'), err
+	}
+	return json.MarshalIndent(v, "", "\t")
+}
+
+// parseBytes parses a quantity of memory in the syntax of the
+// GOMEMLIMIT environment variable: a number of bytes with an
+// optional unit suffix, B, KiB, MiB, GiB, or TiB.
+func parseBytes(s string) (int64, error) {
+	num, unit := s, int64(1)
+	for i, suffix := range []string{"TiB", "GiB", "MiB", "KiB", "B"} {
+		if rest, ok := strings.CutSuffix(s, suffix); ok {
+			num, unit = rest, 1<<(10*(4-i))
+			break
 		}
 	}
-	return "", false
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/unit {
+		return 0, fmt.Errorf("not a number of bytes")
+	}
+	return n * unit, nil
 }
 
-// pathSearch returns the shortest path from one of the roots to one
-// of the targets (along with the root itself), or zero if no path was found.
-func pathSearch(roots []*ssa.Function, res *rta.Result, targets map[*ssa.Function]bool) (*callgraph.Node, []*callgraph.Edge) {
-	// Search breadth-first (for shortest path) from the root.
-	//
-	// We don't use the virtual CallGraph.Root node as we wish to
-	// choose the order in which we search entrypoints:
-	// non-test packages before test packages,
-	// main functions before init functions.
+// isInit reports whether the name is that of a
+// package initializer function, such as "init#1".
+func isInit(name string) bool {
+	if name == "init" {
+		return true
+	}
+	rest, ok := strings.CutPrefix(name, "init#")
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(rest)
+	return err == nil
+}
 
-	// Sort roots into preferred order.
-	importsTesting := func(fn *ssa.Function) bool {
-		isTesting := func(p *types.Package) bool { return p.Path() == "testing" }
-		return containsFunc(fn.Pkg.Pkg.Imports(), isTesting)
+// sectionNames are the names of the sections of -group=kind, in order.
+var sectionNames = []string{"functions", "methods", "variables", "constants", "fields", "types", "cases", "interface methods", "imports"}
+
+// sectionOf returns the name of the -group=kind section of a function.
+func sectionOf(f jsonFunction) string {
+	switch f.Kind {
+	case "func":
+		return cond(f.IsMethod, "methods", "functions")
+	case "var":
+		return "variables"
+	case "const":
+		return "constants"
+	case "imethod":
+		return "interface methods"
 	}
-	sort.Slice(roots, func(i, j int) bool {
-		x, y := roots[i], roots[j]
-		xtest := importsTesting(x)
-		ytest := importsTesting(y)
-		if xtest != ytest {
-			return !xtest // non-tests before tests
+	return f.Kind + "s" // fields, types, cases, imports
+}
+
+// isVendored reports whether the file belongs to a vendored package,
+// that is, whether its name has a vendor directory segment.
+func isVendored(filename string) bool {
+	return strings.Contains(filepath.ToSlash(filename), "/vendor/")
+}
+
+// isPublic reports whether the name, such as "F" or "T.M",
+// denotes part of a package's API: that is, whether the function
+// and, for a method or field, the type are exported.
+func isPublic(name string) bool {
+	for _, id := range strings.Split(name, ".") {
+		if !token.IsExported(id) {
+			return false
 		}
-		xinit := x.Name() == "init"
-		yinit := y.Name() == "init"
-		if xinit != yinit {
-			return !xinit // mains before inits
+	}
+	return true
+}
+
+// printCSV prints the dead functions (and other declarations) of the
+// specified packages as CSV records, preceded by a header, for the
+// -csv flag.
+func printCSV(packages []any) {
+	w := csv.NewWriter(stdout)
+	w.Write([]string{"package", "function", "file", "line", "col", "generated", "kind"})
+	for _, pkg := range packages {
+		pkg := pkg.(jsonPackage)
+		for _, fn := range pkg.Funcs {
+			w.Write([]string{
+				pkg.Path,
+				fn.Name,
+				fn.Position.File,
+				strconv.Itoa(fn.Position.Line),
+				strconv.Itoa(fn.Position.Col),
+				strconv.FormatBool(fn.Generated),
+				fn.Kind,
+			})
 		}
-		return false
-	})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	search := func(allowDynamic bool) (*callgraph.Node, []*callgraph.Edge) {
-		// seen maps each encountered node to its predecessor on the
-		// path to a root node, or to nil for root itself.
-		seen := make(map[*callgraph.Node]*callgraph.Edge)
-		bfs := func(root *callgraph.Node) []*callgraph.Edge {
-			queue := []*callgraph.Node{root}
-			seen[root] = nil
-			for len(queue) > 0 {
-				node := queue[0]
-				queue = queue[1:]
-
-				// found a path?
-				if targets[node.Func] {
-					path := []*callgraph.Edge{} // non-nil in case len(path)=0
-					for {
-						edge := seen[node]
-						if edge == nil {
-							reverse(path)
-							return path
-						}
-						path = append(path, edge)
-						node = edge.Caller
-					}
-				}
+// reportedAs returns the adjective that describes the reported
+// functions: "dynamic-only" with -dynamic-only, "reachable" with
+// -report-reachable ("unexecuted" with -coverprofile too), and
+// otherwise the specified one, for dead code.
+func reportedAs(dead string) string {
+	switch {
+	case *dynamicOnly:
+		return "dynamic-only"
+	case *reportLive:
+		return cond(*coverFlag != "", "unexecuted", "reachable")
+	}
+	return dead
+}
 
-				for _, edge := range node.Out {
-					if allowDynamic || isStaticCall(edge) {
-						if _, ok := seen[edge.Callee]; !ok {
-							seen[edge.Callee] = edge
-							queue = append(queue, edge.Callee)
-						}
-					}
-				}
-			}
-			return nil
+// printCount prints a one-line summary of the number of dead
+// functions in the specified packages, for the -count flag.
+func printCount(packages []any, ngenerated int) {
+	nfuncs := 0
+	for _, pkg := range packages {
+		nfuncs += len(pkg.(jsonPackage).Funcs)
+	}
+	fmt.Fprintf(stdout, "%d %s functions in %d packages", nfuncs, reportedAs("dead"), len(packages))
+	if ngenerated > 0 {
+		fmt.Fprintf(stdout, " (%d more in generated files)", ngenerated)
+	}
+	fmt.Fprintln(stdout)
+}
+
+// printDirSummary prints the number of dead functions in each
+// directory, largest first, for routing cleanup work to the owners
+// of the directories.
+func printDirSummary(packages []any) {
+	counts := make(map[string]int)
+	for _, pkg := range packages {
+		for _, f := range pkg.(jsonPackage).Funcs {
+			counts[filepath.Dir(f.Position.File)]++
 		}
-		for _, rootFn := range roots {
-			root := res.CallGraph.Nodes[rootFn]
-			if root == nil {
-				// Missing call graph node for root.
-				// TODO(adonovan): seems like a bug in rta.
-				continue
-			}
-			if path := bfs(root); path != nil {
-				return root, path
-			}
+	}
+	dirs := keys(counts)
+	sort.Slice(dirs, func(i, j int) bool {
+		if x, y := counts[dirs[i]], counts[dirs[j]]; x != y {
+			return x > y
 		}
-		return nil, nil
+		return dirs[i] < dirs[j]
+	})
+	for _, dir := range dirs {
+		fmt.Fprintf(stdout, "%s: %d\n", dir, counts[dir])
 	}
+}
 
-	for _, allowDynamic := range []bool{false, true} {
-		if root, path := search(allowDynamic); path != nil {
-			return root, path
-		}
+// useColor reports whether to highlight the text output, according to
+// the -color flag. In auto mode, it does so only if the output is a
+// terminal and the NO_COLOR environment variable is not set.
+func useColor() bool {
+	switch *colorFlag {
+	case "always":
+		return true
+	case "never":
+		return false
 	}
+	return isTerminal(stdout) && os.Getenv("NO_COLOR") == ""
+}
 
-	return nil, nil
+// useLinks reports whether to show positions in the text output as
+// hyperlinks, according to the -links flag. Like highlighting, links
+// are shown only if the output is a terminal, unless -color=always.
+func useLinks() bool {
+	return *linksFlag && *colorFlag != "never" && (*colorFlag == "always" || isTerminal(stdout))
 }
 
-// -- utilities --
+// link returns the text, by default the position itself, as a
+// terminal hyperlink (an OSC 8 escape sequence) whose target is a
+// file URL for the line of the position, such as
+// "file://host/a/b/c.go:12", which many terminals and editors open.
+func link(posn jsonPosition, text ...string) string {
+	filename := posn.File
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(posnDir, filename)
+	}
+	host, _ := os.Hostname()
+	u := url.URL{Scheme: "file", Host: host, Path: filepath.ToSlash(filename)}
+	label := posn.String()
+	if len(text) > 0 {
+		label = strings.Join(text, "")
+	}
+	const osc8 = "\x1b]8;;"
+	const st = "\x1b\\"
+	return fmt.Sprintf("%s%s:%d%s%s%s%s", osc8, u.String(), posn.Line, st, label, osc8, st)
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
 
-func isStaticCall(edge *callgraph.Edge) bool {
-	return edge.Site != nil && edge.Site.Common().StaticCallee() != nil
+// printStats prints the number of reachable functions, the total
+// number of functions, and the percentage of them that are dead.
+func printStats(w io.Writer, ntotal, ndead int) {
+	percent := 0.0
+	if ntotal > 0 {
+		percent = 100 * float64(ndead) / float64(ntotal)
+	}
+	fmt.Fprintf(w, "%d of %d functions reachable (%.1f%% dead)\n", ntotal-ndead, ntotal, percent)
 }
 
+// -- utilities --
+
 var cwd, _ = os.Getwd()
 
+// posnDir is the directory to which reported file names are relative,
+// according to the -relative and -trim-prefix flags.
+var posnDir = cwd
+
 func toJSONPosition(posn token.Position) jsonPosition {
-	// Use cwd-relative filename if possible.
+	// Use posnDir-relative filename if possible.
 	filename := posn.Filename
-	if rel, err := filepath.Rel(cwd, filename); err == nil && !strings.HasPrefix(rel, "..") {
+	if rel, err := filepath.Rel(posnDir, filename); err == nil && !strings.HasPrefix(rel, "..") {
 		filename = rel
 	}
 
 	return jsonPosition{filename, posn.Line, posn.Column}
 }
 
+// toJSONFunction returns the JSON form of a dead function
+// of the package with the specified path.
+func toJSONFunction(pkgpath string, fn deadcode.Function) jsonFunction {
+	return jsonFunction{
+		Kind:      fn.Kind,
+		Name:      fn.Name,
+		Position:  toJSONPosition(fn.Position),
+		Offset:    fn.Position.Offset,
+		EndLine:   fn.End.Line,
+		EndCol:    fn.End.Column,
+		Generated: fn.Generated,
+		Generator: fn.Generator,
+		Exported:  fn.Exported,
+		IsMethod:  fn.IsMethod,
+		Recv:      fn.Recv,
+		Pkg:       pkgpath,
+		TypeArgs:  fn.TypeArgs,
+		Variant:   fn.Variant,
+		Signature: fn.Signature,
+		Lines:     fn.Lines,
+		ID:        findingID(pkgpath, fn),
+	}
+}
+
+// findingID returns a stable identifier for a dead function of the
+// package with the specified path: a hash of the path, name, and
+// signature, which, unlike the position, does not change as the
+// surrounding code is edited.
+func findingID(pkgpath string, fn deadcode.Function) string {
+	h := sha256.Sum256([]byte(pkgpath + "\x00" + fn.Name + "\x00" + fn.Signature))
+	return hex.EncodeToString(h[:8])
+}
+
+// globRegexp returns a regular expression that matches the package
+// paths matched by the pattern, in which "..." matches any string, as
+// in "go list". As a special case, a pattern ending in "/..." also
+// matches the path before it, so "net/..." matches both "net" and
+// the packages beneath it.
+func globRegexp(glob string) string {
+	re := regexp.QuoteMeta(glob)
+	re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+	if rest, ok := strings.CutSuffix(re, `/.*`); ok {
+		re = rest + `(/.*)?`
+	}
+	return "^" + re + "$"
+}
+
+// matchAny reports whether any of the regular expressions matches s.
+func matchAny(res []*regexp.Regexp, s string) bool {
+	return containsFunc(res, func(re *regexp.Regexp) bool { return re.MatchString(s) })
+}
+
+// A stringList is a flag.Value for a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, " ") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
 func cond[T any](cond bool, t, f T) T {
 	if cond {
 		return t
@@ -578,21 +1896,53 @@ func cond[T any](cond bool, t, f T) T {
 // Keep in sync with doc comment!
 
 type jsonFunction struct {
+	Kind      string       // = func | var | const | field | type | case | imethod | import
 	Name      string       // name (sans package qualifier)
 	Position  jsonPosition // file/line/column of declaration
+	Offset    int          // byte offset of declaration
+	EndLine   int          // line of end of declaration, or 0 if unknown
+	EndCol    int          // column just after end of declaration, or 0 if unknown
 	Generated bool         // function is declared in a generated .go file
+	Generator string       // name of program that generated the file, if known
+	Exported  bool         // name is exported
+	IsMethod  bool         // function is a method
+	Recv      string       // type of receiver, such as "*T", for a method; empty for others
+	Pkg       string       // import path of package
+	TypeArgs  []string     `json:",omitempty"` // type arguments of each (dead) instantiation of a generic function, such as "[int]"
+	Variant   string       `json:",omitempty"` // ID of the package variant, such as "p [p.test]" (-no-dedup only)
+	Signature string       // type of function (sans receiver); empty for var and const
+	Lines     int          // number of source lines in declaration, or 0 if unknown
+	ID        string       // stable identifier (hash of package path, name, and signature)
+
+	OtherPosns []string `json:",omitempty"` // positions of same-named variants (-dedup-by=name only)
 }
 
 func (f jsonFunction) String() string { return f.Name }
 
 type jsonPackage struct {
-	Name  string         // declared name
-	Path  string         // full import path
-	Funcs []jsonFunction // non-empty list of package's dead functions
+	Name     string         // declared name
+	Path     string         // full import path
+	Funcs    []jsonFunction // non-empty list of package's dead functions
+	Files    []jsonFile     `json:",omitempty"` // same functions grouped by file (-group=file only)
+	Sections []jsonSection  `json:",omitempty"` // same functions grouped by kind (-group=kind only)
 }
 
 func (p jsonPackage) String() string { return p.Path }
 
+type jsonFile struct {
+	Name  string         // name of file
+	Funcs []jsonFunction // non-empty list of file's dead functions
+}
+
+func (f jsonFile) String() string { return f.Name }
+
+type jsonSection struct {
+	Name  string         // = functions | methods | variables | constants | fields | types | cases | interface methods | imports
+	Funcs []jsonFunction // non-empty list of section's dead functions
+}
+
+func (s jsonSection) String() string { return s.Name }
+
 // The Initial and Callee names are package-qualified.
 type jsonEdge struct {
 	Initial  string `json:",omitempty"` // initial entrypoint (main or init); first edge only
@@ -601,6 +1951,53 @@ type jsonEdge struct {
 	Callee   string
 }
 
+// Mains are the paths of the main packages that reach the package.
+type jsonReach struct {
+	Path  string   // full import path
+	Mains []string // paths of main packages, sorted
+}
+
+// A jsonFuncStatus describes the reachability of a function,
+// for -explain-package.
+type jsonFuncStatus struct {
+	Name      string       // name (sans package qualifier)
+	Position  jsonPosition // file/line/column of declaration
+	Reachable bool         // function is reachable
+	Root      bool         // function is a root
+	Caller    string       // package-qualified name of some reachable caller, if any
+	CallSite  jsonPosition // file/line/column of the call by Caller, if any
+}
+
+// A jsonBinaryFunc is a function on which the analysis and the
+// symbol table of an executable disagree, for -binary.
+type jsonBinaryFunc struct {
+	Name     string       // name (sans package qualifier)
+	Position jsonPosition // file/line/column of declaration
+	Symbol   string       // name in the symbol table, such as "example.com/p.(*T).f"
+	Dead     bool         // function is unreachable yet present; otherwise reachable yet absent
+}
+
+// A jsonRoot is a root of the analysis, for -print-roots.
+type jsonRoot struct {
+	Name     string       // qualified name of function
+	Position jsonPosition // file/line/column of declaration, or zero if synthetic
+}
+
+type jsonReport struct {
+	SchemaVersion int   `json:"schemaVersion"`
+	Packages      []any `json:"packages"`
+}
+
+type jsonLoadError struct {
+	Error    string              `json:"error"`
+	Packages []jsonPackageErrors `json:"packages"`
+}
+
+type jsonPackageErrors struct {
+	Path   string   `json:"path"`
+	Errors []string `json:"errors"`
+}
+
 type jsonPosition struct {
 	File      string
 	Line, Col int
@@ -627,21 +2024,6 @@ func indexFunc[S ~[]E, E any](s S, f func(E) bool) int {
 	return -1
 }
 
-func index[S ~[]E, E comparable](s S, v E) int {
-	for i := range s {
-		if v == s[i] {
-			return i
-		}
-	}
-	return -1
-}
-
-func reverse[S ~[]E, E any](s S) {
-	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-		s[i], s[j] = s[j], s[i]
-	}
-}
-
 func keys[M ~map[K]V, K comparable, V any](m M) []K {
 	r := make([]K, 0, len(m))
 	for k := range m {
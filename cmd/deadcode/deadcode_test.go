@@ -60,14 +60,25 @@ func Test(t *testing.T) {
 			// Parse archive comment as directives of these forms:
 			//
 			//  [!]deadcode args...	command-line arguments
+			//  [!]deadcode(N) args...	same, expecting exit status N
 			//  [!]want arg		expected/unwanted string in output (or stderr)
+			//  [!]stdout arg		expected/unwanted string in stdout
+			//  [!]stderr arg		expected/unwanted string in stderr
+			//  [!]exists file		file written (non-empty) or not by the command
+			//  needs tool		skip the archive unless the tool (e.g. cgo) is available
+			//  go args...		run the go command, such as to build an executable
 			//
 			// Args may be Go-quoted strings.
 			type testcase struct {
 				linenum int
+				goCmd   bool // run the go command instead
 				args    []string
 				wantErr bool
+				status  int             // expected exit status, or -1 if unspecified
 				want    map[string]bool // string -> sense
+				stdout  map[string]bool // string -> sense, for stdout
+				stderr  map[string]bool // string -> sense, for stderr
+				exists  map[string]bool // file name -> sense
 			}
 			var cases []*testcase
 			var current *testcase
@@ -81,13 +92,28 @@ func Test(t *testing.T) {
 				if err != nil {
 					t.Fatalf("cannot break line into words: %v (%s)", err, line)
 				}
-				switch kind := words[0]; kind {
+				kind, status := words[0], -1
+				if prefix, rest, ok := strings.Cut(kind, "("); ok && strings.HasSuffix(rest, ")") {
+					n, err := strconv.Atoi(strings.TrimSuffix(rest, ")"))
+					if err != nil {
+						t.Fatalf("%s: invalid exit status in %q", filename, kind)
+					}
+					kind, status = prefix, n
+					if kind != "deadcode" && kind != "!deadcode" {
+						t.Fatalf("%s: exit status not allowed in %q directive", filename, kind)
+					}
+				}
+				switch kind {
 				case "deadcode", "!deadcode":
 					current = &testcase{
 						linenum: i + 1,
 						want:    make(map[string]bool),
+						stdout:  make(map[string]bool),
+						stderr:  make(map[string]bool),
+						exists:  make(map[string]bool),
 						args:    words[1:],
 						wantErr: kind[0] == '!',
+						status:  status,
 					}
 					cases = append(cases, current)
 				case "want", "!want":
@@ -98,6 +124,34 @@ func Test(t *testing.T) {
 						t.Fatalf("'want' directive needs argument <<%s>>", line)
 					}
 					current.want[words[1]] = kind[0] != '!'
+				case "stdout", "!stdout", "stderr", "!stderr":
+					if current == nil {
+						t.Fatalf("'%s' directive must be after 'deadcode'", kind)
+					}
+					if len(words) != 2 {
+						t.Fatalf("'%s' directive needs argument <<%s>>", kind, line)
+					}
+					if strings.HasSuffix(kind, "stdout") {
+						current.stdout[words[1]] = kind[0] != '!'
+					} else {
+						current.stderr[words[1]] = kind[0] != '!'
+					}
+				case "go":
+					cases = append(cases, &testcase{linenum: i + 1, goCmd: true, args: words[1:]})
+					current = nil
+				case "exists", "!exists":
+					if current == nil {
+						t.Fatalf("'%s' directive must be after 'deadcode'", kind)
+					}
+					if len(words) != 2 {
+						t.Fatalf("'%s' directive needs argument <<%s>>", kind, line)
+					}
+					current.exists[words[1]] = kind[0] != '!'
+				case "needs":
+					if len(words) != 2 {
+						t.Fatalf("'needs' directive needs argument <<%s>>", line)
+					}
+					testenv.NeedsTool(t, words[1])
 				default:
 					t.Fatalf("%s: invalid directive %q", filename, kind)
 				}
@@ -105,6 +159,16 @@ func Test(t *testing.T) {
 
 			for _, tc := range cases {
 				t.Run(fmt.Sprintf("L%d", tc.linenum), func(t *testing.T) {
+					if tc.goCmd {
+						cmd := exec.Command("go", tc.args...)
+						cmd.Dir = tmpdir
+						cmd.Env = append(os.Environ(), "GOPROXY=", "GO111MODULE=on")
+						if out, err := cmd.CombinedOutput(); err != nil {
+							t.Fatalf("go %s failed: %v\n%s", strings.Join(tc.args, " "), err, out)
+						}
+						return
+					}
+
 					// Run the command.
 					cmd := exec.Command(exe, tc.args...)
 					cmd.Stdout = new(bytes.Buffer)
@@ -130,19 +194,37 @@ func Test(t *testing.T) {
 					} else {
 						got = fmt.Sprint(cmd.Stdout)
 					}
-					// Check each want directive.
-					for str, sense := range tc.want {
-						ok := true
-						if strings.Contains(got, str) != sense {
+					if code := cmd.ProcessState.ExitCode(); tc.status >= 0 && code != tc.status {
+						t.Errorf("exit status %d, want %d", code, tc.status)
+					}
+					// Check each want, stdout, and stderr directive.
+					check := func(got string, want map[string]bool) {
+						for str, sense := range want {
+							ok := true
+							if strings.Contains(got, str) != sense {
+								if sense {
+									t.Errorf("missing %q", str)
+								} else {
+									t.Errorf("unwanted %q", str)
+								}
+								ok = false
+							}
+							if !ok {
+								t.Errorf("got: <<%s>>", got)
+							}
+						}
+					}
+					check(got, tc.want)
+					check(fmt.Sprint(cmd.Stdout), tc.stdout)
+					check(fmt.Sprint(cmd.Stderr), tc.stderr)
+					for name, sense := range tc.exists {
+						info, err := os.Stat(filepath.Join(tmpdir, name))
+						if written := err == nil && info.Size() > 0; written != sense {
 							if sense {
-								t.Errorf("missing %q", str)
+								t.Errorf("file %s not written", name)
 							} else {
-								t.Errorf("unwanted %q", str)
+								t.Errorf("file %s unexpectedly written", name)
 							}
-							ok = false
-						}
-						if !ok {
-							t.Errorf("got: <<%s>>", got)
 						}
 					}
 				})
@@ -0,0 +1,91 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// This file defines the -diff and -changed-files features, which
+// restrict the report to dead functions declared in the files changed
+// by a pull request, so that preexisting dead code is not reported.
+
+// diffFiles returns the set of files changed between the merge base
+// of the specified git revision and HEAD, as absolute file names.
+func diffFiles(base string) (map[string]bool, error) {
+	// git reports file names relative to the top of the work tree.
+	// Use the relative path of the top from the current directory,
+	// not its absolute path, so that file names agree with those
+	// reported by the build system even if the current directory
+	// is reached through a symbolic link.
+	cdup, err := git("rev-parse", "--show-cdup")
+	if err != nil {
+		return nil, err
+	}
+	cdup = strings.TrimSpace(cdup)
+	names, err := git("diff", "--name-only", base+"...HEAD")
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	for _, name := range strings.Split(names, "\n") {
+		if name != "" {
+			fmt.Fprintln(&text, filepath.Join(cdup, filepath.FromSlash(name)))
+		}
+	}
+	return fileSet(text.String())
+}
+
+// git runs git with the specified arguments and returns its output.
+func git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// The first line of git's error output is the most informative.
+		if msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); msg != "" {
+			return "", fmt.Errorf("%s: %v", cmd, msg)
+		}
+		return "", fmt.Errorf("%s: %v", cmd, err)
+	}
+	return string(out), nil
+}
+
+// readChangedFiles returns the set of files listed, one per line, in
+// the specified file, as absolute file names. Relative names are
+// relative to the current directory.
+func readChangedFiles(filename string) (map[string]bool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return fileSet(string(data))
+}
+
+// fileSet returns the set of absolute file names denoted by the
+// non-blank lines of the text.
+func fileSet(text string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		abs, err := filepath.Abs(line)
+		if err != nil {
+			return nil, err
+		}
+		files[abs] = true
+	}
+	return files, nil
+}
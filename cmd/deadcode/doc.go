@@ -17,31 +17,428 @@ underlying build system if you are using an alternative
 golang.org/x/go/packages driver). Only executable (main) packages are
 considered starting points for the analysis.
 
+The -config=file flag reads default values for the other flags from
+a JSON file, so that a project's policy, such as its filters, build
+tags, and allowlist, can be kept under version control instead of
+being repeated in every script. If the flag is not specified, the
+file deadcode.json in the current directory, if any, is read. The
+file holds a JSON object whose keys are flag names and whose values
+are strings, numbers, or booleans, or arrays of them for flags that
+may be repeated:
+
+	{
+		"filter": ["example.com/repo/internal"],
+		"tags": "integration",
+		"generated": true
+	}
+
+Flags specified on the command line override those in the file.
+File names within it are interpreted relative to the current directory.
+
+The -pkgfile=file flag reads additional package patterns from the
+named file (or from the standard input, if the name is "-"), one per
+line, ignoring blank lines and lines beginning with '#'. This avoids
+limits on the length of the command line when there are many patterns.
+
+The -tags flag specifies additional build tags, as for "go build".
+The -buildflag flag, which may be repeated, passes an arbitrary flag
+such as -mod=mod or -gcflags=... to the build system when loading
+packages, so you can control how they are loaded precisely. Flags of
+"go list" that determine what information is loaded, such as -json or
+-deps, may not be specified, nor may -buildflag=-tags be combined with
+-tags.
+
 The -test flag causes it to analyze test executables too. Tests
 sometimes make use of functions that would otherwise appear to be dead
 code, and public API functions reported as dead with -test indicate
 possible gaps in your test coverage. Bear in mind that an Example test
 function without an "Output:" comment is merely documentation:
-it is dead code, and does not contribute coverage.
+it is dead code, and does not contribute coverage. Conversely, the
+TestMain function of a package, if any, is called by the main function
+that the go command generates for its tests, so the functions that
+TestMain calls, such as setup helpers, are live too.
+
+A function that appears in several test executables is reported
+once, since the variants have the same position; but occasionally
+(for example, in cgo-preprocessed files) the variants' positions
+differ. The -dedup-by=name flag causes the tool to coalesce dead
+functions of the same name within a package, reporting the first and
+recording the positions of the others in the OtherPosns field of the
+JSON output.
+
+Conversely, the -no-dedup flag, a diagnostic aid for investigating
+suspected mistakes of the analysis, causes the tool to report each
+variant of a function that is unreachable in its own right, even if
+another variant is reachable, such as a function that the program
+calls but the test of its package does not. Each is annotated with
+the package variant that declares it, such as "p [p.test]", which is
+also recorded in the Variant field of the JSON output. A generic
+function is reachable if any of its instantiations is.
+
+Functions declared in _test.go files, such as unused test helpers,
+may be reported as dead with -test too. The -exclude-test-files flag
+suppresses them, so that -test can improve the accuracy of the
+analysis while the report focuses on the code that is not tests.
+
+The -include-tests-only flag causes the tool to analyze the program
+twice, once without tests and once with them, and to report only the
+functions that are dead in the first run but not the second. Such
+functions are used only by tests, and are candidates for deletion or
+for moving into a _test.go file.
 
 The -filter flag restricts results to packages that match the provided
 regular expression; its default value is the module name of the first
-package. Use -filter= to display all results.
+package. In a workspace (see go.work), the default also includes the
+modules of the other packages named on the command line that belong to
+the workspace. Use -filter= to display all results. The flag may be repeated,
+in which case a package need match only one of the expressions.
+The -exclude flag, which may also be repeated, suppresses results for
+packages matching the provided regular expression, even if they match
+a filter. For a targeted cleanup, the -only=path,... flag restricts
+the results further to the packages with exactly the specified import
+paths, avoiding the pitfalls of regular expressions. None of these
+flags affects the analysis, which always considers the whole program.
+
+The -filter-glob flag, which may also be repeated, is an alternative
+to -filter that accepts a package pattern in which "..." matches any
+string, as with "go list", avoiding the need to escape regular
+expression metacharacters. For example, -filter-glob=example.com/...
+matches example.com and every package beneath it. A package that
+matches either a -filter or a -filter-glob flag is reported; if either
+flag is set, the default filter does not apply. If the filters match
+none of the analyzed packages, the command prints a warning naming some
+of the packages that were analyzed, since this usually indicates a
+mistake in the filters.
 
 Example: show all dead code within the gopls module:
 
 	$ deadcode -test golang.org/x/tools/gopls/...
 
+When there are several main packages (or test executables), each is
+analyzed as a separate program, and a function is reported as dead
+only if it is unreachable in all of them. The analyses run in
+parallel; the -p=n flag limits the number of concurrent analyses,
+which defaults to GOMAXPROCS.
+
+Analyzing a very large program may need a lot of memory. The
+-memlimit=n flag sets a soft limit on the memory used by the tool,
+with the same syntax and effect as the GOMEMLIMIT environment
+variable, such as -memlimit=4GiB: the garbage collector runs more
+often as the limit is approached, at some cost in speed. This may
+keep the tool within the memory of a CI container, but it cannot
+reduce the memory needed for the program itself, which must be loaded
+in its entirety. Use -p=1 too, so that only one executable's analysis
+is in memory at a time.
+
+The -entry=name flag causes the tool to treat the named function as
+an additional root of every executable, as if it were called from
+main. This is useful for functions called from outside the program,
+such as those looked up by a host program that loads a plugin.
+The name must be fully qualified, as in example.com/pkg.Func,
+example.com/pkg.T.Method, or example.com/pkg.(*T).Method. The flag
+may be repeated. If it is used, the packages need not include any
+main package. When the entry points are many, or generated by a build
+tool, the -entry-file=file flag reads more of them from a file holding
+a JSON array of names:
+
+	["example.com/plugin.Init", "example.com/plugin.(*Handler).Serve"]
+
+If some of the names cannot be found, the error lists all of them.
+
+Frameworks for web services and RPC often call functions through
+reflection according to a naming convention, such as a "Handle"
+prefix. Rather than naming each one with -entry, use the
+-root-regexp=expr flag, which treats every non-generic function of the
+specified packages whose name, relative to its package (such as
+"HandleLogin" or "Server.HandleLogin"), matches the regular expression
+as an additional root. If it is used, the packages need not include
+any main package. Use -print-roots to check which functions match.
+
+The -lib flag makes the tool useful for libraries: if none of the
+packages is a main package, it treats the exported functions and
+methods of the packages (and their init functions) as roots, on the
+assumption that all of the library's API is used. The tool then
+reports the unexported helpers that nothing calls. Generic functions
+are not roots, since their instantiations are unknown.
+
+The -algo=cha flag causes the tool to use Class Hierarchy Analysis
+(CHA) instead of RTA. CHA is faster and uses less memory, but it is
+less precise: it assumes that a dynamic call may reach any function or
+method of the appropriate type, even if the method's receiver type is
+never instantiated, so it may fail to report some dead functions.
+Also, unlike RTA, it does not consider exported methods of types that
+may be inspected by reflection to be reachable.
+
+The -quick flag causes the tool to follow only static calls from the
+roots, ignoring calls through interfaces and function values, which
+is fast enough for interactive use, such as in a pre-commit hook. The
+results are approximate: every function called only dynamically is
+reported as dead, so they may contain false positives, and the tool
+prints a warning to that effect. The -quick-depth=N flag additionally
+limits the traversal to functions within N static calls of a root,
+reporting the rest as dead too.
+
+A program that calls methods chosen at run time, using
+reflect.Value.Call or MethodByName, may call methods of types the
+analysis cannot see, such as those of a plugin, so in that case the
+tool prints a warning. The -reflect=conservative flag causes the
+tool instead to treat every exported method of every named type in
+the program as reachable whenever it finds such a call, avoiding false
+positives at the cost of missing some dead methods.
+
 The analysis can soundly analyze dynamic calls though func values,
-interface methods, and reflection. However, it does not currently
-understand the aliasing created by //go:linkname directives, so it
-will fail to recognize that calls to a linkname-annotated function
-with no body in fact dispatch to the function named in the annotation.
-This may result in the latter function being spuriously reported as dead.
+interface methods, and reflection. It does not model the aliasing
+created by //go:linkname directives precisely; instead, it treats
+both the local function and the target function named by each
+directive in the program as additional roots of the analysis, since
+the linker may make them callable from places the analysis cannot see.
+Similarly, each function exported to C by a cgo //export directive is
+a root, since its callers in C are invisible to the analysis. The
+wrapper functions that cgo generates for its own use are never reported.
+
+A function declared in Go without a body and implemented in assembly,
+such as a //go:noescape stub, is treated like any other: it is live if
+it is called, and dead otherwise, whether or not it has a body. (Such
+a function is recognized by its lack of a body in SSA form and by a
+TEXT directive defining it in a .s file of its package.) The calls
+made by assembly code are invisible to the analysis, so each Go
+function of a package that is referenced by its .s files, as in
+"CALL ·helper(SB)", is a root.
+
+The init functions of a package that is not part of any executable
+are dead code too. Packages that rely on init functions for their side
+effects, such as registration, may find these reports unhelpful; the
+-no-init flag suppresses them.
+
+The tool does not report dead functions in vendored packages (those
+in a vendor directory), which are not yours to fix, even if they match
+a -filter. Use -no-vendor=false to include them. (Packages outside
+the main module are excluded by the default -filter in any case.)
+
+Unused exported functions of a library are not always a mistake: they
+may exist for the benefit of other modules. The -keep-exported flag
+causes the tool not to report the API of each package: exported
+functions, and exported methods of exported types. (Likewise for
+variables, constants, fields, and types reported with -vars, -fields,
+or -types.) Leave it off when analyzing commands, whose API is of no
+use to anyone.
+
+The -unexported-only flag goes further, reporting only the functions
+and methods whose own names are unexported, for cleaning up private
+helpers without second-guessing any contracts: unlike -keep-exported,
+it also suppresses exported methods of unexported types, which may
+be required to satisfy an interface.
+
+The -exclude-main flag suppresses the dead functions of main packages,
+which in many projects are thin wrappers around libraries whose dead
+code is of more interest. The main packages are still analyzed, and
+their functions are still the roots of reachability.
+
+The -skip-files flag, which may be repeated, suppresses dead functions
+declared in files whose name matches the provided regular expression,
+such as legacy files full of functions kept on purpose. The names are
+absolute, so a pattern such as "/legacy/" or "_frozen\.go$" is
+advisable. Only the report is affected, not the analysis: the skipped
+files still count as callers, so a function called only by a dead
+function in a skipped file is still reported as dead.
+
+The -exclude-func flag, which may be repeated, suppresses dead
+functions whose name, relative to their package (such as "T.f" or
+"(*T).f"), matches the provided regular expression. It is useful
+when a naming convention, such as a "_mock" suffix, identifies
+functions that are not worth reporting. It applies in addition to the
+package filters.
 
 By default, the tool does not report dead functions in generated files,
 as determined by the special comment described in
-https://go.dev/s/generatedcode. Use the -generated flag to include them.
+https://go.dev/s/generatedcode, or a variant of it with other
+punctuation at the end, such as "DO NOT EDIT!". Use the -generated
+flag to include them. Some generators write a different comment, such
+as "// Autogenerated."; the -generated-regexp flag specifies a regular
+expression that identifies them: a file is also considered generated
+if the text of any comment before its package declaration matches it.
+
+Functions called by generated code are live, even if no handwritten
+code calls them. For a stricter check, the -ignore-generated-callers
+flag causes the tool to ignore the calls made from generated files, so
+that handwritten functions invoked only by generated glue are reported
+as dead. (The generated functions themselves are still reported only
+with -generated.)
+
+The -vars flag causes the tool to report package-level variables and
+constants that are not used by reachable code, in addition to functions.
+A variable is used if a reachable function (including a package
+initializer) loads from it or takes its address; merely assigning to
+it does not count as a use. Because constants do not survive into the
+SSA representation, a constant is considered used if it is referenced
+anywhere other than within a dead function, including in the
+declaration of another variable or constant.
+
+The -fields flag causes the tool to report the fields of named struct
+types that are not read by reachable code, such as fields that are
+written but never read. A field is read if reachable code loads its
+value or takes its address for a purpose other than storing to it.
+Exported fields of types that may appear in an interface value are
+considered read, since they may be read through reflection (for
+example, by encoding/json). The analysis is approximate: it ignores
+embedded fields, and implicit reads of every field of a struct such as
+comparisons, copies, and reflection on unexported fields (for example,
+by fmt), so some reported fields may in fact be needed. The name of
+a field record is that of its type, followed by a dot and the name of
+the field, as in "T.f".
+
+The -types flag causes the tool to report package-level named types
+that are not used by reachable code, with kind "type". A type is used
+if it is referenced anywhere other than within a dead function or its
+own declaration: for example, in a composite literal, conversion, or
+variable declaration, as the receiver of a reachable method, or as
+the type of a field of another type, even an unused one. Interface
+types are treated the same way. Because a type satisfies an interface
+implicitly, without naming it, an interface that is satisfied by
+types in the program but never named by reachable code is reported.
+Type aliases are not reported.
+
+The -switch-cases flag causes the tool to report, with kind "case",
+the type switch cases and type assertions within reachable functions
+that can never succeed, because RTA found that no value of the
+asserted concrete type is ever held in an interface. Each is named
+after its enclosing function and the asserted type, as in
+"handle.(*legacyRequest)", and its position is that of the case or
+assertion. This analysis is approximate, since values may be created
+by means the analysis does not model, and it ignores generic functions,
+whose asserted types may depend on their type arguments. It requires
+-algo=rta.
+
+The -iface-methods flag causes the tool to report, with kind
+"imethod", the methods of interface types used by reachable code that
+are never called through any interface, such as "Shape.Perimeter".
+Removing such methods slims the interface, freeing its implementations
+from the obligation to provide them. A call through another interface
+that has a method of the same name and signature, such as io.Writer's
+Write, counts as a call of the method, since the values of one
+interface may be converted to the other; calls through reflection do
+not.
+
+The -blank-imports flag causes the tool to report, with kind "import",
+the blank imports (import _ "path") of packages that no package
+imports by name and whose initialization appears to have no effect,
+so that importing them is pointless. The position is that of the
+import, and the name is the path of the imported package. This
+analysis is approximate: a package's initialization is deemed to have
+an effect if it calls any function of another package (except to
+initialize a package that only it imports, if that has no effect
+either), makes any dynamic call, or stores to a variable of another
+package. So packages that register themselves, as database drivers
+and image decoders do, are never reported, and neither are those
+that merely initialize their variables by calling other packages.
+Imports of "embed" and "unsafe" are never reported.
+
+RTA considers every exported method of a type that may appear in an
+interface value to be reachable, since it may be called through
+reflection. The -methods flag additionally reports such exported
+methods if they are not the target of any static or dynamic call in
+the call graph. This can reveal unused methods in a library's API, at
+the risk of false positives for methods genuinely called through
+reflection (for example, by text/template).
+
+A method promoted from an embedded field, such as Inner.M promoted to
+Outer by "type Outer struct{ Inner }", is called through a synthetic
+wrapper, Outer.M, that has no declaration of its own. The tool never
+reports such wrappers; instead, the wrapper's call makes the original
+method live whenever the wrapper is reachable, for example when M is
+called dynamically through an interface holding an Outer. Conversely,
+a method that is dead except for being promoted is reported once,
+against its own declaration (Inner.M), however many types embed it;
+with -methods, this includes methods reachable only because the
+embedding type may be inspected by reflection.
+
+Likewise, a generic function is live if any of its instantiations is,
+and is otherwise reported once, against its declaration. If it was
+instantiated only by calls within dead code, the TypeArgs field of
+the Function record (see JSON schema below) lists the type arguments
+of each instantiation, such as "[int]", showing that removing the
+dead callers leaves the function unused.
+
+The -dynamic-only flag causes the tool to report, instead of dead
+functions, the reachable functions that are called only dynamically:
+that is, the target of some call through an interface method or a
+function value, but of no static call. Such functions, typical of
+plugin and registry patterns, are easily broken by refactoring, since
+no call refers to them by name. The report has the same forms as
+usual, with "dynamic-only" in place of "unreachable". The -vars,
+-fields, -types, -switch-cases, -iface-methods, and -blank-imports
+flags have no effect in this mode, and -stats, -sarif, and
+-include-tests-only may not be used with it.
+
+The -report-reachable flag likewise causes the tool to report the
+reachable functions instead of dead ones, in all the usual forms, with
+"reachable" in place of "unreachable". Comparing this set with a
+coverage profile reveals code that is reachable in principle but never
+run in practice. The same restrictions apply as for -dynamic-only.
+
+The -coverprofile=file flag, which requires -report-reachable, performs
+this comparison itself: it reads a coverage profile, as written by
+"go test -coverprofile", and reports only the reachable functions none
+of whose code was executed, with "unexecuted" in place of "reachable".
+Functions whose packages were not instrumented, so that the profile
+holds no information about them, are not reported.
+
+A function whose declaration is immediately preceded by a
+//deadcode:ignore comment (optionally followed by an explanation)
+is never reported, in any output format. This is useful for functions
+that are intentionally unused, such as those kept for compatibility:
+
+	//deadcode:ignore kept for ABI compatibility
+	func OldEntryPoint() { ... }
+
+The -show-ignored flag causes the tool to list the dead functions so
+suppressed on the standard error stream, for auditing.
+
+When you cannot or prefer not to annotate the source, the -allow=file
+flag names a file listing functions that are never reported, one per
+line, in the notation of -entry, such as example.com/pkg.debugHook or
+example.com/pkg.(*T).Method. Blank lines and lines starting with '#'
+are ignored. Variables, constants, and fields may be listed too, as
+in example.com/pkg.T.field.
+
+When adopting the tool in an existing code base, a baseline lets you
+focus on newly dead code. The -baseline-write flag causes the tool to
+record the current dead functions in the file named by -baseline,
+instead of reporting them. Thereafter, the -baseline=file flag
+suppresses every function recorded in the file, so that only
+functions that have become dead since are reported. Functions are
+matched by package path and name, not position, so a baseline remains
+valid as the surrounding code is edited. A baseline file has the same
+form as the output of -json.
+
+	$ deadcode -baseline=deadcode.json -baseline-write ./...
+	$ deadcode -baseline=deadcode.json ./...
+
+To track the trend of the dead code over time, save the output of
+-json (or -jsonl) from each run. The -compare=file flag causes the
+tool to report, instead of the dead functions, how they changed since
+the run whose output is in the file, in three sections: those newly
+dead, those no longer dead (because they were deleted or have become
+reachable), and those still dead. Functions are matched by their ID,
+or, in output that predates IDs, by package path and name. With
+-json, the command prints a Comparison object (see JSON schema below).
+
+	$ deadcode -compare=last-week.json ./...
+	newly dead (1):
+		internal/cache/lru.go:31:6: func example.com/internal/cache.evictAll
+	no longer dead (2):
+		func example.com/internal/legacy.Convert
+		func example.com/internal/legacy.convertV1
+	still dead (0):
+
+Similarly, in the continuous integration of a pull request, the
+-diff=rev flag restricts the report to dead functions declared in
+files changed between the merge base of the git revision rev and
+HEAD, so that preexisting dead code is not reported. Alternatively,
+the -changed-files=file flag names a file listing the changed files,
+one per line, relative to the current directory:
+
+	$ deadcode -diff=origin/main ./...
 
 In any case, just because a function is reported as dead does not mean
 it is unconditionally safe to delete it. For example, a dead function
@@ -49,15 +446,61 @@ may be referenced by another dead function, and a dead method may be
 required to satisfy an interface that is never called.
 Some judgement is required.
 
+Loading and analyzing a large program can take a while. The -v flag
+causes the tool to report its progress on the standard error, logging
+the duration of each phase (loading, SSA construction, analysis, and
+reporting) along with counts of packages and functions. The
+-ssa-cache=dir flag causes the tool to save its findings in the
+specified directory, and to reuse them in later runs over the same
+inputs, so that repeated runs with (for example) different -filter
+flags or output formats are fast. A cache entry is used only if the
+package patterns and the flags that affect the analysis are the same,
+and no file of the program has been modified since, as determined by
+its size and modification time. The cache is not used with -whylive
+or -dot. Stale entries are never deleted, so remove the directory
+from time to time to reclaim space.
+
+To investigate the performance of the tool itself, the -cpuprofile=file
+and -memprofile=file flags write a CPU or memory profile, for "go tool
+pprof", and the -trace=file flag writes an execution trace, for "go
+tool trace", which shows how the analyses of several executables
+overlap. They are written when the command exits, even after -timeout
+or a failure of the analysis, but not after other errors, such as an
+invalid flag.
+
+The -watch flag causes the tool to keep running after its report,
+checking twice a second for changes to the Go files and go.mod files
+of the modules of the packages, and to analyze the program and print
+the report again once the files stop changing, so that it can assist
+a refactoring session. Before each new report, it clears the screen
+if the output is a terminal, or empties the file named by -o. Errors
+in the edited program are reported without stopping the tool. It
+runs until interrupted, and cannot be combined with -whylive, -dot,
+or -baseline-write.
+
 The analysis is valid only for a single GOOS/GOARCH/-tags configuration,
-so a function reported as dead may be live in a different configuration.
-Consider running the tool once for each configuration of interest.
+so a function reported as dead may be live in a different configuration,
+and a function declared in a file excluded by build constraints is not
+considered at all. The -tags-matrix flag accepts a comma-separated list
+of GOOS/GOARCH pairs, such as linux/amd64,windows/arm64, and causes the
+tool to analyze the program in each of those configurations, then
+report a function (or other object) only if it is dead in every
+configuration whose build includes the file that declares it. With
+-tags-matrix, the counts printed by -stats are approximate.
+
+The -tags-diff flag accepts a comma-separated list of build tags, such
+as those that enable an optional feature, and causes the tool to
+analyze the program both with and without them (in addition to any
+-tags), then report the functions that are dead without the tags but
+live with them: the code that the feature keeps alive. Functions
+declared in files built only with the tags are not reported, as their
+build constraints already make plain which feature they belong to.
 Consider using a line-oriented output format (see below) to make it
 easier to compute the intersection of results across all runs.
 
 # Output
 
-The command supports three output formats.
+The command supports six output formats, plus a summary.
 
 With no flags, the command prints the name and location of each dead
 function in the form of a typical compiler diagnostic, for example:
@@ -67,8 +510,74 @@ function in the form of a typical compiler diagnostic, for example:
 	gopls/internal/template/parse.go:414:18: unreachable func: Parsed.WriteNode
 	gopls/internal/template/parse.go:419:18: unreachable func: wrNode.writeNode
 
-With the -json flag, the command prints an array of Package
-objects, as defined by the JSON schema (see below).
+The -line-count flag adds to each line the number of source lines
+spanned by the function declaration, which may help to prioritize
+the removal of larger dead functions:
+
+	a/b/c.go:1:2: unreachable func: T.f (3 lines)
+
+The -context=n flag adds after each line the first n source lines of
+the function, each preceded by its line number, so that the report
+can be triaged without opening the files:
+
+	a/b/c.go:1:2: unreachable func: T.f
+		1	func (T) f() {
+		2		println("hello")
+
+The -min-lines=n flag, which applies to all output formats, suppresses
+dead functions that span fewer than n lines, such as trivial getters,
+to focus attention on the dead code most worth removing.
+
+Within each package, dead functions are listed in order of position,
+by file and then by line. The -sort flag, which applies to all output
+formats, selects another order: -sort=name orders them by name, and
+-sort=size orders them by the number of lines they span, largest
+first, which is convenient for prioritizing cleanup.
+
+The -group=file flag causes the command instead to print the dead
+functions of each package grouped by file, with the line and column
+of each function:
+
+	$ deadcode -group=file -test ./gopls/...
+	golang.org/x/tools/gopls/internal/template
+		gopls/internal/template/parse.go
+			414:18: func Parsed.WriteNode
+			419:18: func wrNode.writeNode
+
+Similarly, the -group=kind flag causes the command to print the dead
+functions of each package in sections, "functions" and "methods"
+(and, with -vars, -fields, -types, -switch-cases, -iface-methods, or
+-blank-imports, "variables", "constants", "fields", "types", "cases",
+"interface methods", and "imports"), which makes a large report
+easier to skim:
+
+	$ deadcode -group=kind ./...
+	example.com/internal/cache
+		functions
+			internal/cache/cache.go:12:6: newLRU
+		methods
+			internal/cache/cache.go:40:16: Cache.Purge
+
+With the -json flag, the command prints a Report object, which holds
+an array of Package objects, as defined by the JSON schema (see below).
+The schema version in the report is incremented whenever the form of
+the records changes, so that programs that consume the output can
+reject a version they do not understand. The deprecated -json-legacy
+flag causes the command to print the array of packages alone, as it
+did before the report was versioned; it will be removed in a future
+release. The -json-compact flag is equivalent to -json, but prints the
+object on a single line, without indentation, which is smaller and
+friendlier to line-based log shippers.
+
+With the -jsonl flag, the command prints the same Package objects in
+the JSON Lines format (https://jsonlines.org): one compact JSON object
+per line, each printed as soon as it is ready, which is convenient for
+streaming consumers.
+
+With the -sarif flag, the command prints a SARIF 2.1.0 log
+(https://sarifweb.azurewebsites.net) in which each dead function is a
+result of the "deadcode" rule, suitable for uploading to code scanning
+services such as GitHub's.
 
 With the -f=template flag, the command executes the specified template
 on each Package record. So, this template shows dead functions grouped
@@ -82,6 +591,157 @@ by package:
 		Parsed.WriteNode
 		wrNode.writeNode
 
+In addition to the standard template functions, templates may use
+base and dir, which return the last element of a file name and the
+rest of it, short, which returns the last segment of a package
+path, and source, which returns the source lines that -context
+would print, given a Position and a number of lines, and link, which
+returns a Position as a hyperlink (see -links). For example:
+
+	$ deadcode -f='{{range .Funcs}}{{printf "%s.%s in %s\n" (short $.Path) .Name (base .Position.File)}}{{end}}' -test ./gopls/...
+	template.Parsed.WriteNode in parse.go
+
+The -format-file=file flag is equivalent to -f, but reads the template
+from the named file, which is convenient for large templates.
+
+Before the analysis, the template is executed once on a sample record
+whose fields have zero values, so that mistakes such as a misspelled
+field name are reported at once, not after a lengthy analysis.
+
+The -format-all flag causes the template to be executed only once, with
+"." bound not to each record but to the list of all of them, so that
+it can compute values across packages. The add function, which returns
+the sum of two integers, helps to compute totals:
+
+	$ deadcode -format-all -f='{{$n := 0}}{{range .}}{{$n = add $n (len .Funcs)}}{{end}}{{$n}} dead functions' ./...
+	42 dead functions
+
+With the -csv flag, the command prints a table in CSV format, with
+a header row followed by one row per dead function, for convenient
+triage in a spreadsheet. The columns are package, function, file,
+line, col, generated, and kind, which is "func" unless flags such as
+-vars cause other declarations to be reported (see the Kind field of
+the JSON schema below):
+
+	package,function,file,line,col,generated,kind
+	golang.org/x/tools/gopls/internal/template,Parsed.WriteNode,gopls/internal/template/parse.go,414,18,false,func
+
+In every output format, file names are relative to the current
+directory if the file lies within it, and absolute otherwise. The
+-relative flag makes them relative to the root directory of the module
+of the first package instead, and the -trim-prefix=dir flag makes them
+relative to the specified directory, so that reports and annotations
+do not depend on where the repository is checked out.
+
+With the -count flag, the command prints only a single line stating the
+number of dead functions and the number of packages that contain them.
+Dead functions omitted because they are declared in generated files are
+counted separately:
+
+	$ deadcode -count -test ./gopls/...
+	42 dead functions in 7 packages (3 more in generated files)
+
+The -summary-by-dir flag causes the command to print instead the
+number of dead functions in each directory, largest first, which is
+convenient for routing cleanup work to the owners of each directory
+(as listed in a CODEOWNERS file, for example):
+
+	$ deadcode -summary-by-dir ./...
+	internal/legacy: 12
+	cmd/tool: 3
+
+The -stats flag causes the command to print, after its other output, a
+line stating the number of reachable functions, the total number of
+functions, and the percentage of them that are dead, across all the
+packages selected by the -filter and -exclude flags. Dead functions
+that are not reported for other reasons, such as those in generated
+files, are still counted as dead. With -json, -jsonl, -sarif, or -csv,
+the line is printed to the standard error so that the output remains
+machine-readable:
+
+	$ deadcode -stats -test ./gopls/...
+	...
+	1925 of 1967 functions reachable (2.1% dead)
+
+When the output is a terminal, the text output is highlighted: package
+paths are shown in bold, function names in color, and functions in
+generated files (see -generated) are marked as such. The -color flag
+controls this: -color=always highlights the output even when it is not
+a terminal, -color=never disables highlighting, and the default,
+-color=auto, also disables it if the NO_COLOR environment variable is
+set. Highlighting does not apply to the -f=template flag or to
+machine-readable formats.
+
+The -links flag causes the text output to show each position as a
+hyperlink (an OSC 8 escape sequence) to a file URL for its line, such
+as file://host/a/b/c.go:12, so that many terminals open the file in an
+editor when the position is clicked. Like highlighting, hyperlinks are
+shown only when the output is a terminal, or with -color=always.
+
+The -o flag causes the command to write its report, in whichever
+format, to the named file instead of the standard output. Warnings,
+errors, and progress messages are still printed to the standard error.
+
+# Exit status
+
+The exit status of the command is:
+
+	0 if no dead code was reported;
+	1 if dead code was reported, or if an error occurred
+	  (for example, the packages could not be loaded);
+	2 if the command line was invalid;
+	3 if dead code was reported and the -set-exit-status (or -c) flag is set.
+
+Modes that list something other than dead code, such as -if-removed,
+-report-reachable, and -dynamic-only, exit with status 0 even if the
+list is not empty. With -report-reachable and -dynamic-only, the
+-set-exit-status and -max flags apply to the listed functions as they
+would to dead ones, so a script can fail if any function is called
+only dynamically:
+
+	$ deadcode -dynamic-only -c ./...
+
+Scripts that gate on the presence of dead code should use
+-set-exit-status, so that failures of the analysis itself can be
+distinguished from its findings.
+
+The -max=n flag tolerates up to n dead functions, as counted by
+-count: the command reports dead code as usual, but fails only if
+it reports more than n functions, printing the excess to the
+standard error. A project with much legacy dead code can commit the
+current count and lower it over time, preventing regressions without
+fixing everything at once:
+
+	$ deadcode -max=120 -c ./...
+
+The -timeout=duration flag bounds the time taken by the analysis, to
+keep CI jobs predictable even for pathological inputs: if the analysis
+has not finished within the duration, such as 5m, the command prints
+an error and exits with status 1, or the status specified by the
+-timeout-exit=n flag, so that a timeout may be distinguished from
+other failures. Once the analysis has finished, the time taken to
+print its results does not count, so the output is never cut short.
+
+The -q flag suppresses warnings, such as those about the precision of
+the analysis or about a -filter that matches nothing, so that the
+command prints only the dead code it finds and any errors. With -q and
+-set-exit-status, as in a pre-commit hook, a clean tree produces no
+output and exit status 0.
+
+With -json, if the program cannot be loaded (for example, because its
+packages contain errors), the command prints to the standard output a
+LoadError object instead of a Report, and exits with status 1:
+
+	type LoadError struct {
+		Error    string          `json:"error"`    // summary of the failure
+		Packages []PackageErrors `json:"packages"` // packages containing errors
+	}
+
+	type PackageErrors struct {
+		Path   string   `json:"path"`   // import path of package
+		Errors []string `json:"errors"` // error messages
+	}
+
 # Why is a function not dead?
 
 The -whylive=function flag explain why the named function is not dead
@@ -95,7 +755,7 @@ preferred over those from tests. Paths from main functions are
 preferred over paths from init functions.
 
 The result is a list of Edge objects (see JSON schema below).
-Again, the -json and -f=template flags may be used to control
+Again, the -json, -jsonl, and -f=template flags may be used to control
 the formatting of the list of Edge objects.
 The default format shows, for each edge in the path, whether the call
 is static or dynamic, and its source line number. For example:
@@ -109,18 +769,156 @@ is static or dynamic, and its source line number. For example:
 	static@L0154 --> golang.org/x/tools/go/internal/packagesdriver.GetSizesForArgsGolist
 	static@L0044 --> bytes.Buffer.String
 
+# What else would become dead?
+
+The -if-removed=function flag causes the command to report the
+functions that would become dead if the named function, in the
+notation of -whylive, were deleted: those reachable from the roots
+only through it. This "blast radius" helps to plan a refactoring.
+The report is filtered and formatted as usual, and the command exits
+with status 0 even if it is not empty. For example:
+
+	$ deadcode -if-removed=example.com/internal/legacy.Migrate ./...
+	internal/legacy/convert.go:12:6: unreachable func: convertV1
+	internal/legacy/convert.go:40:6: unreachable func: convertV2
+
+The query uses the call graph, which omits calls through reflection,
+so functions reachable only through reflection are never reported.
+Only functions are reported, once per declaration: -iface-methods,
+-blank-imports, and -no-dedup are ignored.
+Deleting a function may also make some types disappear from interface
+values, and thus some dynamic calls impossible, so the report may be
+incomplete.
+
+# Call graph
+
+The -dot flag causes the command to print, in the DOT language of
+GraphViz (https://graphviz.org), the call graph of all functions
+reachable from the main and init functions, with each edge labeled
+by the line number of its call site. The -dot-depth=n flag limits the
+graph to functions within n calls of a root. For example:
+
+	$ deadcode -dot -dot-depth=3 ./cmd/deadcode | dot -Tsvg > callgraph.svg
+
+# Which commands reach a package?
+
+In a repository with many commands, the -reachable-from flag causes
+the tool to show, for each package with reachable functions, the main
+packages whose executables reach it. A package reached by only one
+command is likely to become dead code if that command is deleted.
+Each executable is analyzed separately, so this is slower than the
+usual analysis. The -filter, -filter-glob, and -exclude flags select
+the packages as usual. The result is a list of Reach objects (see
+JSON schema below), and the -json, -jsonl, and -f=template flags
+control its formatting. For example:
+
+	$ deadcode -reachable-from ./...
+	example.com/internal/auth
+		example.com/cmd/server
+	example.com/internal/log
+		example.com/cmd/client
+		example.com/cmd/server
+
+# Why is nothing reported?
+
+If a package contains less dead code than expected, the
+-explain-package=path flag causes the tool to show, for each function
+of the package with the specified import path, whether it is
+reachable, and if so, whether it is a root, or otherwise the function
+that calls it (by a static call, if possible) and where. A reachable
+function with neither is reachable only through reflection. The result
+is a list of FuncStatus objects (see JSON schema below), and the
+-json, -jsonl, and -f=template flags control its formatting. For
+example:
+
+	$ deadcode -explain-package=example.com/internal/util ./...
+	internal/util/util.go:5:6: reachable func: Join (called by example.com/cmd/server.main at cmd/server/main.go:20:12)
+	internal/util/util.go:9:6: unreachable func: split
+
+The -whylive flag then shows a complete path to a function.
+
+# Which roots does the analysis use?
+
+When the results are surprising, the -print-roots flag causes the tool
+to print, instead of the dead code, the roots from which it computes
+reachability: the init and main functions of each main package (with
+-test, including the synthetic main packages of test executables),
+followed by the other roots, such as those named by -entry, functions
+exported to C or linked by name, and functions called from assembly.
+The result is a list of Root objects (see JSON schema below), and the
+-json, -jsonl, and -f=template flags control its formatting. For
+example:
+
+	$ deadcode -print-roots ./cmd/server
+	example.com/cmd/server.init
+	example.com/cmd/server.main (cmd/server/main.go:12:6)
+
+# Does the executable agree?
+
+The -binary=file flag cross-checks the analysis against an executable
+built from the program, by reading the names of the functions that
+the linker kept from its symbol table (which survives stripping). It
+prints, instead of the dead code, the unreachable functions that are
+present in the executable, which may indicate a root that the
+analysis missed, and the reachable functions that are absent from it.
+Neither is necessarily a mistake: the linker also keeps some methods
+that may be called through reflection, and it omits functions that
+were inlined at every call site. A generic function is present if
+any instantiation of it is. Initializers and methods of generic types
+are not compared. The result is a list of BinaryFunc objects
+(see JSON schema below), and the -json, -jsonl, and -f=template flags
+control its formatting. For example:
+
+	$ go build -o server ./cmd/server
+	$ deadcode -binary=server ./cmd/server
+	cmd/server/main.go:18:6: reachable func not in binary: small
+	internal/db/db.go:40:6: unreachable func in binary: Conn.Reset
+
 # JSON schema
 
+	type Report struct {
+		SchemaVersion int       `json:"schemaVersion"` // currently 6
+		Packages      []Package `json:"packages"`
+	}
+
 	type Package struct {
-		Name  string       // declared name
-		Path  string       // full import path
+		Name     string     // declared name
+		Path     string     // full import path
+		Funcs    []Function // list of dead functions within it
+		Files    []File     // same functions grouped by file (-group=file only)
+		Sections []Section  // same functions grouped by kind (-group=kind only)
+	}
+
+	type File struct {
+		Name  string       // name of file
+		Funcs []Function   // list of dead functions within it
+	}
+
+	type Section struct {
+		Name  string       // = functions | methods | variables | constants | fields | types | cases | interface methods | imports
 		Funcs []Function   // list of dead functions within it
 	}
 
 	type Function struct {
+		Kind      string   // = func | var | const | field | type | case | imethod | import
 		Name      string   // name (sans package qualifier)
 		Position  Position // file/line/column of function declaration
+		Offset    int      // byte offset of function declaration
+		EndLine   int      // line of end of function, or 0 if unknown
+		EndCol    int      // column just after end of function, or 0 if unknown
 		Generated bool     // function is declared in a generated .go file
+		Generator string   // name of program that generated the file, if known
+		Exported  bool     // name is exported
+		IsMethod  bool     // function is a method
+		Recv      string   // type of receiver, such as "*T", for a method; empty for others
+		Pkg       string   // import path of package
+		TypeArgs  []string // type arguments of each (dead) instantiation of a generic function, such as "[int]"
+		Variant   string   // ID of the package variant, such as "p [p.test]" (-no-dedup only)
+		Signature string   // type of function (sans receiver); empty for var and const
+		Lines     int      // number of source lines in declaration, or 0 if unknown
+		ID        string   // stable identifier (hash of package path, name, and signature)
+
+		OtherPosns []string // positions of same-named variants (-dedup-by=name only)
 	}
 
 	type Edge struct {
@@ -130,9 +928,44 @@ is static or dynamic, and its source line number. For example:
 		Callee   string    // target of the call
 	}
 
+	type Comparison struct {
+		NewlyDead    []Package // dead now but not before
+		NoLongerDead []Package // dead before but not now
+		StillDead    []Package // dead both before and now
+	}
+
+	type FuncStatus struct {
+		Name      string   // name (sans package qualifier)
+		Position  Position // file/line/column of declaration
+		Reachable bool     // function is reachable
+		Root      bool     // function is a root
+		Caller    string   // package-qualified name of some reachable caller, if any
+		CallSite  Position // file/line/column of the call by Caller, if any
+	}
+
+	type Root struct {
+		Name     string    // qualified name of function
+		Position Position  // file/line/column of declaration, or zero if synthetic
+	}
+
+	type BinaryFunc struct {
+		Name     string    // name (sans package qualifier)
+		Position Position  // file/line/column of declaration
+		Symbol   string    // name in the symbol table, such as "example.com/p.(*T).f"
+		Dead     bool      // function is unreachable yet present; otherwise reachable yet absent
+	}
+
+	type Reach struct {
+		Path  string       // full import path
+		Mains []string     // paths of the main packages that reach it, sorted
+	}
+
 	type Position struct {
 		File      string   // name of file
 		Line, Col int      // line and byte index, both 1-based
 	}
+
+Programs that need the results of the analysis without running the
+command may call the golang.org/x/tools/go/deadcode package directly.
 */
 package main
@@ -0,0 +1,222 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"go/token"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/deadcode"
+)
+
+// This file defines the -tags-matrix and -tags-diff features, which
+// analyze the program in several build configurations.
+//
+// The -tags-matrix feature analyzes the program in several
+// GOOS/GOARCH configurations and reports only the code that is dead
+// in all of them, since a function declared in a file excluded by
+// build constraints is invisible to the analysis of a single
+// configuration.
+//
+// The -tags-diff feature analyzes the program with and without some
+// build tags, such as those that enable optional features, and
+// reports the code that is live only with them, to quantify the cost
+// of the features.
+
+// A buildConfig is a configuration of the build
+// in which to analyze the program.
+type buildConfig struct {
+	tags string   // comma-separated build tags
+	env  []string // additional environment variables, such as GOOS=windows
+}
+
+// findDead returns the dead code of the packages denoted by the
+// patterns. If platforms is non-empty, it analyzes each GOOS/GOARCH
+// configuration and merges the results. If diffTags is non-empty, it
+// analyzes the program with and without those build tags and reports
+// the code live only with them. Otherwise, it analyzes the host
+// configuration.
+func findDead(patterns []string, platforms, diffTags []string) (*deadcode.Findings, error) {
+	host := buildConfig{tags: *tagsFlag}
+	if len(diffTags) > 0 {
+		with := host
+		with.tags = strings.Join(diffTags, ",")
+		if host.tags != "" {
+			with.tags = host.tags + "," + with.tags
+		}
+		logf("analyzing with -tags=%s", with.tags)
+		withTags, err := findDeadIn(patterns, with)
+		if err != nil {
+			return nil, err
+		}
+		logf("analyzing with -tags=%s", host.tags)
+		withoutTags, err := findDeadIn(patterns, host)
+		if err != nil {
+			return nil, err
+		}
+		return diffFindings(withTags, withoutTags), nil
+	}
+	if len(platforms) == 0 {
+		return findDeadIn(patterns, host)
+	}
+	var all []*deadcode.Findings
+	for _, platform := range platforms {
+		goos, goarch, _ := strings.Cut(platform, "/")
+		logf("analyzing %s", platform)
+		bc := host
+		bc.env = []string{"GOOS=" + goos, "GOARCH=" + goarch}
+		found, err := findDeadIn(patterns, bc)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, found)
+	}
+	return mergeFindings(platforms, all), nil
+}
+
+// diffFindings returns the findings of the program without some build
+// tags, restricted to the objects that are live with them: that is,
+// those declared in a file that the analysis with the tags included
+// but did not find dead.
+func diffFindings(withTags, withoutTags *deadcode.Findings) *deadcode.Findings {
+	analyzed := make(map[string]bool)
+	for _, file := range withTags.Files {
+		analyzed[file] = true
+	}
+	dead := make(map[token.Position]bool)
+	for _, pkg := range withTags.Packages {
+		for _, fn := range pkg.Funcs {
+			dead[fn.Position] = true
+		}
+	}
+
+	diff := *withoutTags
+	diff.Packages = nil
+	for _, pkg := range withoutTags.Packages {
+		var funcs []deadcode.Function
+		for _, fn := range pkg.Funcs {
+			if analyzed[fn.Position.Filename] && !dead[fn.Position] {
+				funcs = append(funcs, fn)
+			}
+		}
+		if funcs != nil {
+			pkg.Funcs = funcs
+			diff.Packages = append(diff.Packages, pkg)
+		}
+	}
+	return &diff
+}
+
+// mergeFindings returns the findings for all the configurations of a
+// program, given those of each one. An object is dead if it is dead
+// in every configuration whose analysis included the file that
+// declares it.
+func mergeFindings(platforms []string, all []*deadcode.Findings) *deadcode.Findings {
+	// An object is identified by the position of its declaration.
+	type key struct {
+		kind, filename string
+		offset         int
+	}
+	keyOf := func(fn deadcode.Function) key {
+		return key{fn.Kind, fn.Position.Filename, fn.Position.Offset}
+	}
+
+	analyzed := make([]map[string]bool, len(all)) // set of files of each configuration
+	dead := make([]map[key]bool, len(all))        // set of dead objects of each configuration
+	merged := &deadcode.Findings{NumFuncs: make(map[string]int)}
+	seenModules := make(map[string]bool)
+	seenFiles := make(map[string]bool)
+	for i, found := range all {
+		analyzed[i] = make(map[string]bool)
+		for _, file := range found.Files {
+			analyzed[i][file] = true
+			if !seenFiles[file] {
+				seenFiles[file] = true
+				merged.Files = append(merged.Files, file)
+			}
+		}
+		dead[i] = make(map[key]bool)
+		for _, pkg := range found.Packages {
+			for _, fn := range pkg.Funcs {
+				dead[i][keyOf(fn)] = true
+			}
+		}
+		for _, mod := range found.Modules {
+			if !seenModules[mod] {
+				seenModules[mod] = true
+				merged.Modules = append(merged.Modules, mod)
+			}
+		}
+		for _, warning := range found.Warnings {
+			merged.Warnings = append(merged.Warnings, platforms[i]+": "+warning)
+		}
+		// Most functions are common to all configurations,
+		// so the largest count is the best approximation.
+		for pkgpath, n := range found.NumFuncs {
+			if n > merged.NumFuncs[pkgpath] {
+				merged.NumFuncs[pkgpath] = n
+			}
+		}
+	}
+	sort.Strings(merged.Files)
+
+	// Keep the objects that are dead in every configuration
+	// that analyzed their file.
+	byPkgPath := make(map[string]*deadcode.Package)
+	seen := make(map[key]bool)
+	for _, found := range all {
+		for _, pkg := range found.Packages {
+			for _, fn := range pkg.Funcs {
+				k := keyOf(fn)
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				live := false
+				for i := range all {
+					if analyzed[i][k.filename] && !dead[i][k] {
+						live = true
+						break
+					}
+				}
+				if live {
+					continue
+				}
+				p, ok := byPkgPath[pkg.Path]
+				if !ok {
+					p = &deadcode.Package{Name: pkg.Name, Path: pkg.Path}
+					byPkgPath[pkg.Path] = p
+				}
+				p.Funcs = append(p.Funcs, fn)
+			}
+		}
+	}
+
+	// Sort the packages by path, and their objects by position,
+	// then kind and name, as deadcode.Find does.
+	pkgpaths := keys(byPkgPath)
+	sort.Strings(pkgpaths)
+	for _, pkgpath := range pkgpaths {
+		p := byPkgPath[pkgpath]
+		sort.Slice(p.Funcs, func(i, j int) bool {
+			x, y := p.Funcs[i], p.Funcs[j]
+			if x.Position.Filename != y.Position.Filename {
+				return x.Position.Filename < y.Position.Filename
+			}
+			if x.Position.Offset != y.Position.Offset {
+				return x.Position.Offset < y.Position.Offset
+			}
+			if x.Kind != y.Kind {
+				return x.Kind < y.Kind
+			}
+			return x.Name < y.Name
+		})
+		merged.Packages = append(merged.Packages, *p)
+	}
+	return merged
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"runtime/debug"
+)
+
+// This file defines the -sarif output format, a subset of the
+// Static Analysis Results Interchange Format (SARIF) 2.1.0
+// sufficient for GitHub code scanning.
+//
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifRuleID  = "deadcode"
+)
+
+// printSARIF prints the dead functions of the specified packages
+// as a SARIF log containing a single run.
+func printSARIF(packages []any) {
+	results := []sarifResult{} // non-nil: "results" must be an array
+	for _, pkg := range packages {
+		for _, fn := range pkg.(jsonPackage).Funcs {
+			results = append(results, sarifResult{
+				RuleID:  sarifRuleID,
+				Level:   "warning",
+				Message: sarifMessage{Text: "unreachable " + fn.Kind + ": " + fn.Name},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{
+							URI: filepath.ToSlash(fn.Position.File),
+						},
+						Region: sarifRegion{
+							StartLine:   fn.Position.Line,
+							StartColumn: fn.Position.Col,
+						},
+					},
+				}},
+			})
+		}
+	}
+
+	out, err := json.MarshalIndent(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifToolComponent{
+					Name:           "deadcode",
+					Version:        version(),
+					InformationURI: "https://pkg.go.dev/golang.org/x/tools/cmd/deadcode",
+					Rules: []sarifReportingDescriptor{{
+						ID:               sarifRuleID,
+						ShortDescription: sarifMessage{Text: "unreachable function"},
+						FullDescription: sarifMessage{
+							Text: "The function is not reachable from any main or init function of the program.",
+						},
+					}},
+				},
+			},
+			Results: results,
+		}},
+	}, "", "\t")
+	if err != nil {
+		log.Fatalf("internal error: %v", err)
+	}
+	stdout.Write(out)
+}
+
+// version returns the module version of the deadcode executable,
+// or "(devel)" if it is unknown.
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// -- SARIF schema --
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifToolComponent `json:"driver"`
+}
+
+type sarifToolComponent struct {
+	Name           string                     `json:"name"`
+	Version        string                     `json:"version"`
+	InformationURI string                     `json:"informationUri"`
+	Rules          []sarifReportingDescriptor `json:"rules"`
+}
+
+type sarifReportingDescriptor struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// A sarifRegion's line and column numbers are 1-based.
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// This file defines the -watch feature, which reports the dead code
+// again each time the program is edited, so that the tool can assist
+// a refactoring session.
+//
+// To avoid a dependency on a file notification library, it polls the
+// size and modification time of each Go file of the main modules,
+// which is cheap compared to analyzing the program.
+
+// watchInterval is the interval between polls of the files.
+const watchInterval = 500 * time.Millisecond
+
+// A stamp records the state of a file.
+type stamp struct {
+	size    int64
+	modTime int64 // in nanoseconds
+}
+
+// watch calls run, then calls it again each time the Go files of the
+// modules of the packages denoted by the patterns change, after
+// clearing the previous report. It never returns.
+func watch(patterns []string, run func()) {
+	dirs, err := moduleDirs(patterns)
+	if err != nil {
+		log.Fatalf("-watch: %v", err)
+	}
+	logf("watching %s for changes", strings.Join(dirs, ", "))
+
+	prev := snapshot(dirs)
+	clearOutput()
+	run()
+	for {
+		time.Sleep(watchInterval)
+		cur := snapshot(dirs)
+		if sameStamps(cur, prev) {
+			continue
+		}
+
+		// Wait until the files stop changing, since editors and
+		// tools such as gofmt may save several files in quick
+		// succession, and each analysis is expensive.
+		for {
+			time.Sleep(watchInterval)
+			next := snapshot(dirs)
+			if sameStamps(next, cur) {
+				break
+			}
+			cur = next
+		}
+		prev = cur
+
+		clearOutput()
+		run()
+	}
+}
+
+// snapshot returns the stamps of the Go files and go.mod files within
+// the directory trees, ignoring the directories that the go command
+// ignores, such as testdata.
+func snapshot(dirs []string) map[string]stamp {
+	stamps := make(map[string]stamp)
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // file deleted during walk, perhaps
+			}
+			name := d.Name()
+			if d.IsDir() {
+				if path != dir && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(name, ".go") || name == "go.mod" {
+				if info, err := d.Info(); err == nil {
+					stamps[path] = stamp{info.Size(), info.ModTime().UnixNano()}
+				}
+			}
+			return nil
+		})
+	}
+	return stamps
+}
+
+// sameStamps reports whether two snapshots are equal.
+func sameStamps(x, y map[string]stamp) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for path, s := range x {
+		if t, ok := y[path]; !ok || s != t {
+			return false
+		}
+	}
+	return true
+}
+
+// clearOutput discards the previous report: it clears the screen if
+// the output is a terminal, or empties the file named by -o.
+func clearOutput() {
+	if isTerminal(stdout) {
+		fmt.Fprint(stdout, "\x1b[H\x1b[2J")
+	} else if f, ok := stdout.(*os.File); ok && *outputFlag != "" {
+		f.Truncate(0)
+		f.Seek(0, 0)
+	}
+}
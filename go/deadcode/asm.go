@@ -0,0 +1,80 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"go/types"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// This file defines the handling of functions implemented in assembly.
+//
+// A function declared in Go without a body, such as
+//
+//	//go:noescape
+//	func add(x, y int) int
+//
+// and defined by a TEXT directive in a .s file of the same package,
+// is represented in SSA by a function with no blocks (fn.Blocks ==
+// nil). The analysis needs no special treatment for it: like any
+// other function, it is live if and only if it is reachable from
+// some call site (or a root), regardless of whether it has a body.
+//
+// However, the calls made by assembly are invisible to the analysis,
+// so a Go function called only from assembly would be reported as
+// dead. So we treat the Go functions referenced by the assembly files
+// of a package, such as ·helper in "CALL ·helper(SB)", as roots.
+
+var (
+	// asmText matches the symbol defined by a TEXT directive, such as
+	// "TEXT ·add(SB),NOSPLIT,$0-24".
+	asmText = regexp.MustCompile(`^\s*TEXT\s+·(\w+)(?:<\w+>)?\(SB\)`)
+
+	// asmRef matches a reference to a symbol of the same package,
+	// such as "·helper(SB)" or "·helper<ABIInternal>(SB)".
+	asmRef = regexp.MustCompile(`(?:^|[^\w/.])·(\w+)(?:<\w+>)?\(SB\)`)
+)
+
+// asmFuncs returns the functions of the package that are implemented
+// in its assembly files, and those referenced by them (other than
+// from their own TEXT directives), which the analysis treats as roots.
+func asmFuncs(prog *ssa.Program, pkg *packages.Package) (implemented, referenced []*ssa.Function) {
+	lookup := func(name string) *ssa.Function {
+		if obj, ok := pkg.Types.Scope().Lookup(name).(*types.Func); ok {
+			return prog.FuncValue(obj)
+		}
+		return nil // not a Go function: a variable, or defined only in assembly
+	}
+	for _, filename := range pkg.OtherFiles {
+		if !strings.HasSuffix(filename, ".s") {
+			continue
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			continue // file deleted since load, perhaps
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if m := asmText.FindStringSubmatch(line); m != nil {
+				if fn := lookup(m[1]); fn != nil && fn.Blocks == nil {
+					implemented = append(implemented, fn)
+				}
+				continue
+			}
+			for _, m := range asmRef.FindAllStringSubmatch(line, -1) {
+				if fn := lookup(m[1]); fn != nil {
+					referenced = append(referenced, fn)
+				}
+			}
+		}
+	}
+	return implemented, referenced
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A blankImport is an import of a package solely for the side
+// effects of its initialization, for [Config.BlankImports].
+type blankImport struct {
+	pkg  *packages.Package // importing package
+	spec *ast.ImportSpec
+	path string // path of imported package
+}
+
+// staleBlankImports returns the blank imports of packages that no
+// package imports by name, and whose initialization appears to have
+// no effect visible outside them.
+//
+// The analysis is approximate. The initialization of a package is
+// deemed to have an effect if it calls a function of another package
+// (other than the initializer of a package imported only by it, which
+// would be removed along with it), makes a dynamic call, or writes to
+// a variable of another package. So it may miss stale imports, for
+// example of packages that initialize variables by calling functions
+// of the standard library, but it should rarely report a needed one,
+// except of packages that register themselves by means it does not
+// model, such as //go:linkname.
+func staleBlankImports(prog *ssa.Program, initial []*packages.Package) []blankImport {
+	var blanks []blankImport
+	named := make(map[string]bool)                // paths of packages imported by name
+	importers := make(map[string]map[string]bool) // maps package path to paths of importers
+	packages.Visit(initial, nil, func(pkg *packages.Package) {
+		for path := range pkg.Imports {
+			if importers[path] == nil {
+				importers[path] = make(map[string]bool)
+			}
+			importers[path][pkg.PkgPath] = true
+		}
+		for _, file := range pkg.Syntax {
+			for _, spec := range file.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				if spec.Name != nil && spec.Name.Name == "_" {
+					blanks = append(blanks, blankImport{pkg, spec, path})
+				} else {
+					named[path] = true
+				}
+			}
+		}
+	})
+
+	// Group the SSA packages by path, since there may
+	// be several variants of a package with tests.
+	byPath := make(map[string][]*ssa.Package)
+	for _, pkg := range prog.AllPackages() {
+		byPath[pkg.Pkg.Path()] = append(byPath[pkg.Pkg.Path()], pkg)
+	}
+
+	// hasEffects reports whether the initialization of any
+	// variant of the package with the specified path may have
+	// an effect visible outside it, memoizing the results.
+	// (The import graph is acyclic, so the recursion ends.)
+	effects := make(map[string]bool)
+	var hasEffects func(path string) bool
+	hasEffects = func(path string) bool {
+		if result, ok := effects[path]; ok {
+			return result
+		}
+		result := false
+		for _, pkg := range byPath[path] {
+			if initEffects(pkg, func(dep *ssa.Package) bool {
+				// Calling the initializer of a dependency has an
+				// effect only if the package alone imports it, since
+				// the dependency is initialized anyway otherwise.
+				exclusive := len(importers[dep.Pkg.Path()]) == 1 && importers[dep.Pkg.Path()][path]
+				return exclusive && hasEffects(dep.Pkg.Path())
+			}) {
+				result = true
+				break
+			}
+		}
+		effects[path] = result
+		return result
+	}
+
+	var stale []blankImport
+	for _, imp := range blanks {
+		switch imp.path {
+		case "C", "embed", "unsafe":
+			continue // needed for cgo, //go:embed, and //go:linkname
+		}
+		if !named[imp.path] && byPath[imp.path] != nil && !hasEffects(imp.path) {
+			stale = append(stale, imp)
+		}
+	}
+	return stale
+}
+
+// initEffects reports whether the initialization of the package may
+// have an effect visible outside it. The depEffects function reports
+// whether a call of the initializer of a dependency has one.
+func initEffects(pkg *ssa.Package, depEffects func(*ssa.Package) bool) bool {
+	init := pkg.Func("init")
+	if init == nil {
+		return false
+	}
+
+	// Visit the functions of the package that
+	// its initialization calls, transitively.
+	seen := map[*ssa.Function]bool{init: true}
+	queue := []*ssa.Function{init}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case ssa.CallInstruction:
+					common := instr.Common()
+					if builtin, ok := common.Value.(*ssa.Builtin); ok {
+						if name := builtin.Name(); name == "print" || name == "println" {
+							return true
+						}
+						continue
+					}
+					callee := common.StaticCallee()
+					if callee == nil {
+						return true // dynamic call
+					}
+					if orig := callee.Origin(); orig != nil {
+						callee = orig // generic function of instantiation
+					}
+					switch {
+					case callee.Pkg == pkg:
+						if !seen[callee] {
+							seen[callee] = true
+							queue = append(queue, callee)
+						}
+					case callee.Pkg != nil && callee == callee.Pkg.Func("init"):
+						if depEffects(callee.Pkg) {
+							return true
+						}
+					default:
+						return true // call of another package
+					}
+
+				case *ssa.Store:
+					if isExternal(pkg, instr.Addr) {
+						return true
+					}
+
+				case *ssa.MapUpdate:
+					if isExternal(pkg, instr.Map) {
+						return true
+					}
+
+				case *ssa.Send:
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isExternal reports whether the value is, or is derived from, a
+// package-level variable of a package other than pkg, such as the
+// address of a field of one, or a map loaded from one.
+func isExternal(pkg *ssa.Package, v ssa.Value) bool {
+	for {
+		switch x := v.(type) {
+		case *ssa.Global:
+			return x.Pkg != pkg
+		case *ssa.FieldAddr:
+			v = x.X
+		case *ssa.IndexAddr:
+			v = x.X
+		case *ssa.Field:
+			v = x.X
+		case *ssa.Index:
+			v = x.X
+		case *ssa.UnOp:
+			if x.Op != token.MUL {
+				return false
+			}
+			v = x.X // load
+		default:
+			return false
+		}
+	}
+}
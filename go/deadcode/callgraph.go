@@ -0,0 +1,567 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/internal/typesinternal"
+)
+
+// This file defines the functions that explain why functions are
+// live, by reporting the calls that reach them.
+
+// An Edge is a call on a path from a root to a live function.
+type Edge struct {
+	Caller   string         // package-qualified name of calling function
+	Callee   string         // package-qualified name of called function
+	Position token.Position // position of call site
+	Dynamic  bool           // call is dynamic (through an interface or func value)
+}
+
+// WhyLive explains why the function of the program with the specified
+// package-qualified name, such as "example.com/pkg.Func" or
+// "example.com/pkg.T.Method", is live, by returning a path of calls to
+// it from some root. Static calls are preferred to dynamic ones.
+//
+// If the program cannot be loaded, the error is a [*LoadError].
+func WhyLive(cfg Config, name string) ([]Edge, error) {
+	p, err := load(&cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	fset := p.prog.Fset
+
+	targets := make(map[*ssa.Function]bool)
+	for _, fn := range p.sourceFuncs {
+		if prettyName(fn, true) == name {
+			targets[fn] = true
+		}
+	}
+	if len(targets) == 0 {
+		// Function is not part of the program.
+		//
+		// TODO(adonovan): improve the UX here in case
+		// of spelling or syntax mistakes. Some ideas:
+		// - a cmd/callgraph command to enumerate
+		//   available functions.
+		// - a deadcode -live flag to compute the complement.
+		// - a syntax hint: example.com/pkg.Func or (example.com/pkg.Type).Method
+		// - report the element of AllFunctions with the smallest
+		//   Levenshtein distance from name.
+		// - permit -whylive=regexp. But beware of spurious
+		//   matches (e.g. fmt.Print matches fmt.Println)
+		//   and the annoyance of having to quote parens (*T).f.
+		return nil, fmt.Errorf("function %q not found in program", name)
+	}
+
+	// Opt: remove the unreachable ones.
+	for fn := range targets {
+		if !p.reachablePosn[fset.Position(fn.Pos())] {
+			delete(targets, fn)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("function %s is dead code: it is unreachable from any main or init function", name)
+	}
+
+	p.res.CallGraph.DeleteSyntheticNodes() // inline synthetic wrappers (except inits)
+	root, path := pathSearch(p.roots, p.res, targets)
+	if root == nil {
+		// RTA doesn't add callgraph edges for reflective calls.
+		return nil, fmt.Errorf("%s is reachable only through reflection", name)
+	}
+	if len(path) == 0 {
+		// No edges => one of the targets is a root.
+		// Rather than (confusingly) return nothing, make this an error.
+		return nil, fmt.Errorf("%s is a root", root.Func)
+	}
+
+	var edges []Edge
+	for _, edge := range path {
+		edges = append(edges, Edge{
+			Caller:   prettyName(edge.Caller.Func, true),
+			Callee:   prettyName(edge.Callee.Func, true),
+			Position: fset.Position(edge.Site.Pos()),
+			Dynamic:  !isStaticCall(edge),
+		})
+	}
+	return edges, nil
+}
+
+// WriteDOT writes to w, in GraphViz DOT format, the call graph of the
+// program, or the portion of it within the specified number of calls
+// of a root if maxDepth is positive. Each edge is labeled by the line
+// number of its call site.
+//
+// If the program cannot be loaded, the error is a [*LoadError].
+func WriteDOT(w io.Writer, cfg Config, maxDepth int) error {
+	p, err := load(&cfg, true)
+	if err != nil {
+		return err
+	}
+	p.res.CallGraph.DeleteSyntheticNodes() // inline synthetic wrappers (except inits)
+	return printDOT(w, p.prog.Fset, p.roots, p.res.CallGraph, maxDepth)
+}
+
+// IfRemoved reports the functions of the program that would become
+// dead if the function with the specified package-qualified name (as
+// for [WhyLive]) were deleted, along with the calls it makes: that is,
+// those reachable from the roots only through it. The function itself
+// is not reported. Vars, Fields, Types, SwitchCases, Methods,
+// InterfaceMethods, BlankImports, DynamicOnly, and NoDedup are
+// ignored.
+//
+// The query is answered using the call graph, in which calls through
+// reflection are absent, so functions reachable only through
+// reflection are never reported. Also, the call graph is that of the
+// whole program: deleting the function may remove some types from
+// the runtime types, and thus some dynamic call edges too, so the
+// result is an underestimate.
+//
+// If the program cannot be loaded, the error is a [*LoadError].
+func IfRemoved(cfg Config, name string) (*Findings, error) {
+	cfg.Vars, cfg.Fields, cfg.Types, cfg.SwitchCases = false, false, false, false
+	cfg.Methods, cfg.InterfaceMethods, cfg.BlankImports = false, false, false
+	cfg.DynamicOnly, cfg.NoDedup = false, false
+	p, err := load(&cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	fset := p.prog.Fset
+
+	targets := make(map[*ssa.Function]bool)
+	for _, fn := range p.sourceFuncs {
+		if prettyName(fn, true) == name {
+			targets[fn] = true
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("function %q not found in program", name)
+	}
+	if !containsFunc(keys(targets), func(fn *ssa.Function) bool { return p.reachablePosn[fset.Position(fn.Pos())] }) {
+		return nil, fmt.Errorf("function %s is dead code already: it is unreachable from any main or init function", name)
+	}
+
+	// Compare the functions reachable in the call graph
+	// with and without the calls made by the targets.
+	cg := p.res.CallGraph
+	before := reachableVia(fset, cg, p.roots, func(*ssa.Function) bool { return true })
+	after := reachableVia(fset, cg, p.roots, func(fn *ssa.Function) bool { return !targets[fn] })
+	for fn := range targets {
+		after[fset.Position(fn.Pos())] = true // not reported
+	}
+
+	// Report the functions reached before but not after,
+	// by treating every other function as reachable.
+	p.reachablePosn = make(map[token.Position]bool)
+	for _, fn := range p.sourceFuncs {
+		if posn := fset.Position(fn.Pos()); !before[posn] || after[posn] {
+			p.reachablePosn[posn] = true
+		}
+	}
+	return p.findings(), nil
+}
+
+// A FuncStatus describes whether a function is reachable,
+// and if so, how, for [ExplainPackage].
+type FuncStatus struct {
+	Name      string         // name (sans package qualifier), such as "T.f"
+	Position  token.Position // position of declaration
+	Reachable bool           // function is reachable
+	Root      bool           // function is a root
+	Caller    string         // package-qualified name of some reachable caller, if any
+	CallSite  token.Position // position of the call by Caller, if any
+}
+
+// ExplainPackage reports, for each function declared in the package
+// with the specified path, in order of position, whether it is
+// reachable, and if so, whether it is a root or which function calls
+// it. A reachable function with no caller and that is not a root is
+// reachable only through reflection. Static calls are preferred to
+// dynamic ones.
+//
+// If the program cannot be loaded, the error is a [*LoadError].
+func ExplainPackage(cfg Config, pkgpath string) ([]FuncStatus, error) {
+	p, err := load(&cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	fset := p.prog.Fset
+	p.res.CallGraph.DeleteSyntheticNodes() // inline synthetic wrappers (except inits)
+
+	roots := make(map[*ssa.Function]bool)
+	for _, fn := range p.roots {
+		roots[fn] = true
+	}
+	var result []FuncStatus
+	seen := make(map[token.Position]bool)
+	for _, fn := range p.sourceFuncs {
+		posn := fset.Position(fn.Pos())
+		if fn.Pkg.Pkg.Path() != pkgpath || seen[posn] {
+			continue
+		}
+		seen[posn] = true // suppress dups with same pos
+		status := FuncStatus{
+			Name:      prettyName(fn, false),
+			Position:  posn,
+			Reachable: p.reachablePosn[posn],
+			Root:      roots[fn],
+		}
+		if status.Reachable && !status.Root {
+			if node := p.res.CallGraph.Nodes[fn]; node != nil {
+				// Choose a reachable caller: by a static call
+				// if possible, then the first by name.
+				var best *callgraph.Edge
+				for _, edge := range node.In {
+					if _, ok := p.res.Reachable[edge.Caller.Func]; !ok || edge.Site == nil {
+						continue
+					}
+					if best == nil ||
+						isStaticCall(edge) && !isStaticCall(best) ||
+						isStaticCall(edge) == isStaticCall(best) && edge.Caller.Func.String() < best.Caller.Func.String() {
+						best = edge
+					}
+				}
+				if best != nil {
+					status.Caller = prettyName(best.Caller.Func, true)
+					status.CallSite = fset.Position(best.Site.Pos())
+				}
+			}
+		}
+		result = append(result, status)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no functions of package %s in program", pkgpath)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		x, y := result[i].Position, result[j].Position
+		if x.Filename != y.Filename {
+			return x.Filename < y.Filename
+		}
+		return x.Offset < y.Offset
+	})
+	return result, nil
+}
+
+// reachableVia returns the positions of the functions reachable from
+// the roots in the call graph, following the calls made by each
+// function for which follow returns true.
+func reachableVia(fset *token.FileSet, cg *callgraph.Graph, roots []*ssa.Function, follow func(*ssa.Function) bool) map[token.Position]bool {
+	reached := make(map[token.Position]bool)
+	seen := make(map[*callgraph.Node]bool)
+	var queue []*callgraph.Node
+	for _, root := range roots {
+		if node := cg.Nodes[root]; node != nil && !seen[node] {
+			seen[node] = true
+			queue = append(queue, node)
+		}
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		reached[fset.Position(node.Func.Pos())] = true
+		if !follow(node.Func) {
+			continue
+		}
+		for _, edge := range node.Out {
+			if !seen[edge.Callee] {
+				seen[edge.Callee] = true
+				queue = append(queue, edge.Callee)
+			}
+		}
+	}
+	return reached
+}
+
+// A Reach records the executables of a program that reach
+// a package, for [ReachableFrom].
+type Reach struct {
+	Path  string   // package path
+	Mains []string // paths of the main packages whose executables reach the package, sorted
+}
+
+// Reachability holds the executables that reach each package of a
+// program, for [ReachableFrom].
+type Reachability struct {
+	Modules  []string // paths of the modules of the initial packages
+	Packages []Reach  // packages with reachable functions, in order of path
+}
+
+// ReachableFrom reports, for each package of the program with
+// reachable functions, the main packages from whose roots they are
+// reachable. A package reachable from only one main package is
+// likely to become dead code if that command is deleted.
+//
+// It analyzes each executable separately, so the result does not
+// depend on how the call graph of one executable overlaps another's.
+//
+// If the program cannot be loaded, the error is a [*LoadError].
+func ReachableFrom(cfg Config) (*Reachability, error) {
+	p, err := load(&cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.mains) == 0 {
+		return nil, fmt.Errorf("no main packages")
+	}
+
+	mains := make(map[string]map[string]bool) // maps package path to set of main package paths
+	for i, main := range p.mains {
+		res := analyze(&cfg, p.prog, p.rootGroups[i:i+1], false)
+		for fn := range res.Reachable {
+			if fn.Pkg == nil {
+				continue // synthetic, e.g. a wrapper
+			}
+			path := fn.Pkg.Pkg.Path()
+			if mains[path] == nil {
+				mains[path] = make(map[string]bool)
+			}
+			mains[path][main.Pkg.Path()] = true
+		}
+	}
+
+	result := &Reachability{Modules: p.modules}
+	for path, set := range mains {
+		reach := Reach{Path: path, Mains: keys(set)}
+		sort.Strings(reach.Mains)
+		result.Packages = append(result.Packages, reach)
+	}
+	sort.Slice(result.Packages, func(i, j int) bool {
+		return result.Packages[i].Path < result.Packages[j].Path
+	})
+	return result, nil
+}
+
+// A Root is a function from which the analysis computes
+// reachability, for [Roots].
+type Root struct {
+	Name     string         // qualified name, such as "example.com/cmd.main"
+	Position token.Position // position of declaration; invalid for synthetic functions
+}
+
+// Roots returns the roots of the analysis, in order: the init and
+// main functions of each main package, followed by the additional
+// roots, such as those named by [Config.Entry], those exported to C
+// or linked by name, and those called from assembly. With Tests, the
+// main packages include the synthetic ones of the test executables.
+//
+// If the program cannot be loaded, the error is a [*LoadError].
+func Roots(cfg Config) ([]Root, error) {
+	p, err := load(&cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	var roots []Root
+	seen := make(map[*ssa.Function]bool)
+	for _, fn := range p.roots {
+		if !seen[fn] {
+			seen[fn] = true
+			roots = append(roots, Root{Name: fn.String(), Position: p.prog.Fset.Position(fn.Pos())})
+		}
+	}
+	return roots, nil
+}
+
+// printDOT prints, in GraphViz DOT format, the portion of the call
+// graph reachable from the roots within the specified number of calls
+// (or all of it, if maxDepth is zero). Each edge is labeled by the
+// line number of its call site.
+func printDOT(w io.Writer, fset *token.FileSet, roots []*ssa.Function, cg *callgraph.Graph, maxDepth int) error {
+	// Search breadth-first from the roots, recording the depth of each node.
+	depth := make(map[*callgraph.Node]int)
+	var queue []*callgraph.Node
+	for _, root := range roots {
+		if node := cg.Nodes[root]; node != nil {
+			if _, ok := depth[node]; !ok {
+				depth[node] = 0
+				queue = append(queue, node)
+			}
+		}
+	}
+	var lines []string
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if maxDepth > 0 && depth[node] >= maxDepth {
+			continue
+		}
+		for _, edge := range node.Out {
+			label := ""
+			if edge.Site != nil {
+				label = fmt.Sprintf("L%d", fset.Position(edge.Site.Pos()).Line)
+			}
+			lines = append(lines, fmt.Sprintf("\t%q -> %q [label=%q];\n",
+				prettyName(edge.Caller.Func, true),
+				prettyName(edge.Callee.Func, true),
+				label))
+			if _, ok := depth[edge.Callee]; !ok {
+				depth[edge.Callee] = depth[node] + 1
+				queue = append(queue, edge.Callee)
+			}
+		}
+	}
+
+	// The order of edges in the graph is not deterministic,
+	// so sort the output lines, and remove duplicates.
+	sort.Strings(lines)
+	var buf bytes.Buffer
+	buf.WriteString("digraph deadcode {\n")
+	for i, line := range lines {
+		if i == 0 || line != lines[i-1] {
+			buf.WriteString(line)
+		}
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// pathSearch returns the shortest path from one of the roots to one
+// of the targets (along with the root itself), or zero if no path was found.
+func pathSearch(roots []*ssa.Function, res *rta.Result, targets map[*ssa.Function]bool) (*callgraph.Node, []*callgraph.Edge) {
+	// Search breadth-first (for shortest path) from the root.
+	//
+	// We don't use the virtual CallGraph.Root node as we wish to
+	// choose the order in which we search entrypoints:
+	// non-test packages before test packages,
+	// main functions before init functions.
+
+	// Sort roots into preferred order.
+	importsTesting := func(fn *ssa.Function) bool {
+		if fn.Pkg == nil {
+			return false // synthetic, e.g. a wrapper
+		}
+		isTesting := func(p *types.Package) bool { return p.Path() == "testing" }
+		return containsFunc(fn.Pkg.Pkg.Imports(), isTesting)
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		x, y := roots[i], roots[j]
+		xtest := importsTesting(x)
+		ytest := importsTesting(y)
+		if xtest != ytest {
+			return !xtest // non-tests before tests
+		}
+		xinit := x.Name() == "init"
+		yinit := y.Name() == "init"
+		if xinit != yinit {
+			return !xinit // mains before inits
+		}
+		return false
+	})
+
+	search := func(allowDynamic bool) (*callgraph.Node, []*callgraph.Edge) {
+		// seen maps each encountered node to its predecessor on the
+		// path to a root node, or to nil for root itself.
+		seen := make(map[*callgraph.Node]*callgraph.Edge)
+		bfs := func(root *callgraph.Node) []*callgraph.Edge {
+			queue := []*callgraph.Node{root}
+			seen[root] = nil
+			for len(queue) > 0 {
+				node := queue[0]
+				queue = queue[1:]
+
+				// found a path?
+				if targets[node.Func] {
+					path := []*callgraph.Edge{} // non-nil in case len(path)=0
+					for {
+						edge := seen[node]
+						if edge == nil {
+							reverse(path)
+							return path
+						}
+						path = append(path, edge)
+						node = edge.Caller
+					}
+				}
+
+				for _, edge := range node.Out {
+					if allowDynamic || isStaticCall(edge) {
+						if _, ok := seen[edge.Callee]; !ok {
+							seen[edge.Callee] = edge
+							queue = append(queue, edge.Callee)
+						}
+					}
+				}
+			}
+			return nil
+		}
+		for _, rootFn := range roots {
+			root := res.CallGraph.Nodes[rootFn]
+			if root == nil {
+				// Missing call graph node for root.
+				// TODO(adonovan): seems like a bug in rta.
+				continue
+			}
+			if path := bfs(root); path != nil {
+				return root, path
+			}
+		}
+		return nil, nil
+	}
+
+	for _, allowDynamic := range []bool{false, true} {
+		if root, path := search(allowDynamic); path != nil {
+			return root, path
+		}
+	}
+
+	return nil, nil
+}
+
+// prettyName is a fork of Function.String designed to reduce
+// go/ssa's fussy punctuation symbols, e.g. "(*pkg.T).F" -> "pkg.T.F".
+//
+// It only works for functions that remain after
+// callgraph.Graph.DeleteSyntheticNodes: source-level named functions
+// and methods, their anonymous functions, and synthetic package
+// initializers.
+func prettyName(fn *ssa.Function, qualified bool) string {
+	var buf strings.Builder
+
+	// optional package qualifier
+	if qualified && fn.Pkg != nil {
+		fmt.Fprintf(&buf, "%s.", fn.Pkg.Pkg.Path())
+	}
+
+	var format func(*ssa.Function)
+	format = func(fn *ssa.Function) {
+		// anonymous?
+		if fn.Parent() != nil {
+			format(fn.Parent())
+			i := index(fn.Parent().AnonFuncs, fn)
+			fmt.Fprintf(&buf, "$%d", i+1)
+			return
+		}
+
+		// method receiver?
+		if recv := fn.Signature.Recv(); recv != nil {
+			_, named := typesinternal.ReceiverNamed(recv)
+			buf.WriteString(named.Obj().Name())
+			buf.WriteByte('.')
+		}
+
+		// function/method name
+		buf.WriteString(fn.Name())
+	}
+	format(fn)
+
+	return buf.String()
+}
+
+func isStaticCall(edge *callgraph.Edge) bool {
+	return edge.Site != nil && edge.Site.Common().StaticCallee() != nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A deadCase is a type assertion, or a case of a type switch, that
+// can never succeed, for [Config.SwitchCases].
+type deadCase struct {
+	fn     *ssa.Function // enclosing declared function
+	assert *ssa.TypeAssert
+}
+
+// deadCases returns the type assertions and type switch cases within
+// reachable functions that test for a concrete type that RTA did not
+// find among the runtime types: since no interface value may hold a
+// value of such a type, the test can never succeed.
+//
+// The result is approximate: it assumes that the runtime types are
+// complete, which they are unless values are created by means the
+// analysis does not model, such as unsafe conversions. Assertions
+// within generic functions are ignored, since the asserted type may
+// depend on the type arguments.
+func deadCases(res *rta.Result) []deadCase {
+	var cases []deadCase
+	for fn := range res.Reachable {
+		if fn.Blocks == nil || fn.TypeParams().Len() > 0 || fn.Origin() != nil {
+			continue // no body, or generic
+		}
+		decl := fn
+		for decl.Parent() != nil {
+			decl = decl.Parent() // function literal
+		}
+		if decl.TypeParams().Len() > 0 || decl.Origin() != nil {
+			continue // within a generic function
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				assert, ok := instr.(*ssa.TypeAssert)
+				if !ok || !assert.Pos().IsValid() || types.IsInterface(assert.AssertedType) {
+					continue
+				}
+				if res.RuntimeTypes.At(assert.AssertedType) == nil {
+					cases = append(cases, deadCase{decl, assert})
+				}
+			}
+		}
+	}
+	return cases
+}
@@ -0,0 +1,1252 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/callgraph/static"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// A Config specifies a program and how to analyze it.
+type Config struct {
+	// Dir is the directory in which to run the build system to load
+	// the packages. If empty, the current directory is used.
+	Dir string
+
+	// Patterns denote the packages of the program, as in "go list".
+	Patterns []string
+
+	// Tests causes the tests of the packages to be analyzed too.
+	Tests bool
+
+	// Tags is a comma-separated list of build tags.
+	Tags string
+
+	// Env holds environment variables, such as "GOOS=windows", to
+	// add to those of the current process when running the build
+	// system, so that a configuration other than the host's may be
+	// analyzed.
+	Env []string
+
+	// BuildFlags are additional flags for the build system, such as
+	// "-mod=mod". They must not include -tags, or flags such as -json
+	// that the loader itself sets.
+	BuildFlags []string
+
+	// Filter restricts the results of Analyze to packages whose path
+	// matches one of these regular expressions. If empty, the default
+	// is the modules of the initial packages (see Findings.Modules).
+	Filter []string
+
+	// Generated causes Analyze to report dead functions declared in
+	// generated Go files, which it otherwise omits.
+	Generated bool
+
+	// IgnoreGeneratedCallers causes the calls made by functions
+	// declared in generated Go files to be ignored, so that the
+	// handwritten functions called only by generated code are
+	// reported as dead.
+	IgnoreGeneratedCallers bool
+
+	// GeneratedRegexp, if not empty, is a regular expression that
+	// identifies generated Go files that lack the standard comment
+	// (see https://go.dev/s/generatedcode): a file is generated if
+	// the text of any comment before its package declaration, such
+	// as "// Autogenerated.", matches it.
+	GeneratedRegexp string
+
+	// Entry names additional roots of the analysis, such as
+	// "example.com/pkg.Func" or "example.com/pkg.(*Type).Method".
+	// If any of them cannot be found, the error lists them all.
+	Entry []string
+
+	// RootRegexp, if not empty, is a regular expression that
+	// identifies additional roots by name: every non-generic
+	// function of the initial packages whose name (sans package
+	// qualifier, such as "HandleLogin" or "Server.HandleLogin")
+	// matches it is a root. This suits frameworks that call
+	// functions by reflection according to a naming convention.
+	RootRegexp string
+
+	// Library causes the exported API of the initial packages to be
+	// treated as roots if there are no main packages.
+	Library bool
+
+	// Algorithm is the call graph algorithm used to compute
+	// reachability: "rta" (the default), "cha", or "static", which
+	// follows only static calls and is fast but approximate: it
+	// reports as dead the functions called only dynamically.
+	Algorithm string
+
+	// MaxDepth, if positive, limits the "static" algorithm to the
+	// functions within that many static calls of a root.
+	MaxDepth int
+
+	// Reflection is the treatment of methods called by name through
+	// reflection: "precise" (the default), which reports a warning
+	// if the program does so, or "conservative", which then treats
+	// every exported method as a root.
+	Reflection string
+
+	// Methods causes exported methods that are reachable only because
+	// they may be called through reflection, not by any call, to be
+	// reported as dead.
+	Methods bool
+
+	// DynamicOnly causes the reachable functions that are called
+	// only dynamically, through an interface method or a function
+	// value, to be reported instead of the dead ones. Vars, Fields,
+	// Types, SwitchCases, InterfaceMethods, and BlankImports are
+	// then ignored.
+	DynamicOnly bool
+
+	// Reachable causes the reachable functions to be reported
+	// instead of the dead ones, for comparison with coverage
+	// profiles. Vars, Fields, Types, SwitchCases, InterfaceMethods,
+	// and BlankImports are then ignored. It may not be combined
+	// with DynamicOnly.
+	Reachable bool
+
+	// NoDedup causes each distinct ssa.Function of a declaration,
+	// such as the variant of a package compiled for a test
+	// executable, to be reported if it is unreachable, rather than
+	// treating a declaration as reachable if any of its variants
+	// is, and records the variant in Function.Variant. A generic
+	// function is reachable if any of its instantiations is. It is a
+	// diagnostic aid for investigating the analysis itself, and may
+	// not be combined with DynamicOnly or Reachable.
+	NoDedup bool
+
+	// Vars causes package-level variables and constants not used by
+	// reachable code to be reported, with Kind "var" or "const".
+	Vars bool
+
+	// Fields causes struct fields not read by reachable code to be
+	// reported, with Kind "field".
+	Fields bool
+
+	// Types causes package-level named types not used by reachable
+	// code to be reported, with Kind "type".
+	Types bool
+
+	// SwitchCases causes the type assertions and type switch cases
+	// within reachable functions that can never succeed, because
+	// no value of the asserted type is ever held in an interface,
+	// to be reported, with Kind "case" and a Name such as
+	// "F.(*T)". It requires the "rta" algorithm.
+	SwitchCases bool
+
+	// InterfaceMethods causes the methods of named interface types
+	// used by reachable code that are never the target of a dynamic
+	// call to be reported, with Kind "imethod" and a Name such as
+	// "I.M". Such methods may be removed from the interface.
+	InterfaceMethods bool
+
+	// BlankImports causes the blank imports of packages that no
+	// package imports by name, and whose initialization appears to
+	// have no effect outside them, to be reported, with Kind
+	// "import" and the imported package path as the Name. The
+	// analysis is approximate.
+	BlankImports bool
+
+	// Parallel is the maximum number of executables to analyze in
+	// parallel. If zero, it is GOMAXPROCS.
+	Parallel int
+
+	// Logf, if not nil, is called to log the progress of each phase
+	// of the analysis.
+	Logf func(format string, args ...any)
+}
+
+// A Package is a package that contains dead code.
+type Package struct {
+	Name  string     // declared name
+	Path  string     // full import path
+	Funcs []Function // non-empty list of package's dead functions, in order of position
+}
+
+// A Function is a dead function, or an unused variable, constant,
+// struct field, or type, an impossible type switch case, an uncalled
+// interface method, or a stale blank import (see Config.Vars,
+// Config.Fields, Config.Types, Config.SwitchCases,
+// Config.InterfaceMethods, and Config.BlankImports).
+type Function struct {
+	Kind      string         // = func | var | const | field | type | case | imethod | import
+	Name      string         // name (sans package qualifier), such as "T.f"
+	Position  token.Position // position of declaration
+	End       token.Position // end of declaration, if known
+	Generated bool           // declared in a generated .go file
+	Generator string         // name of program that generated the file, if known
+	Exported  bool           // name is exported
+	IsMethod  bool           // function is a method
+	Recv      string         // type of receiver, such as "*T", for a method; empty for others
+	TypeArgs  []string       // type arguments of each (dead) instantiation of a generic function, such as "[int]"
+	Signature string         // type of function (sans receiver); empty for others
+	Lines     int            // number of source lines in declaration, or 0 if unknown
+	Ignored   bool           // declaration has a //deadcode:ignore comment
+	Variant   string         // with Config.NoDedup, ID of the package variant, such as "p [p.test]"
+}
+
+// Findings holds the dead code of a program, before filtering.
+type Findings struct {
+	Modules  []string       // paths of the modules of the initial packages
+	Warnings []string       // warnings about the precision of the analysis (sans "warning: ")
+	Packages []Package      // packages that contain dead code, in order of path
+	NumFuncs map[string]int // number of functions in each package, by path
+	Files    []string       // names of the Go files of all analyzed packages, sorted
+}
+
+// A LoadError reports that the program could not be loaded.
+type LoadError struct {
+	Msg      string          // summary, such as "packages contain errors"
+	Packages []PackageErrors // errors of each erroneous package, if any
+}
+
+func (e *LoadError) Error() string { return e.Msg }
+
+// PackageErrors holds the errors of a package and of its module.
+type PackageErrors struct {
+	Path   string
+	Errors []string
+}
+
+// Analyze analyzes the program and returns the packages that contain
+// dead code, in order of path. It omits packages that do not match
+// cfg.Filter, dead functions annotated with //deadcode:ignore
+// comments, and, unless cfg.Generated, those declared in generated
+// files.
+//
+// If the program cannot be loaded, the error is a [*LoadError].
+func Analyze(cfg Config) ([]Package, error) {
+	var filters []*regexp.Regexp
+	for _, expr := range cfg.Filter {
+		filter, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %v", err)
+		}
+		filters = append(filters, filter)
+	}
+
+	found, err := Find(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(filters) == 0 {
+		for _, mod := range found.Modules {
+			filters = append(filters, regexp.MustCompile("^"+regexp.QuoteMeta(mod)+`\b`))
+		}
+	}
+
+	var result []Package
+	for _, pkg := range found.Packages {
+		if len(filters) > 0 && !containsFunc(filters, func(re *regexp.Regexp) bool { return re.MatchString(pkg.Path) }) {
+			continue
+		}
+		var funcs []Function
+		for _, fn := range pkg.Funcs {
+			if !fn.Ignored && (!fn.Generated || cfg.Generated) {
+				funcs = append(funcs, fn)
+			}
+		}
+		if len(funcs) > 0 {
+			pkg.Funcs = funcs
+			result = append(result, pkg)
+		}
+	}
+	return result, nil
+}
+
+// Find analyzes the program and returns all its dead code.
+//
+// If the program cannot be loaded, the error is a [*LoadError].
+func Find(cfg Config) (*Findings, error) {
+	p, err := load(&cfg, cfg.Methods || cfg.DynamicOnly)
+	if err != nil {
+		return nil, err
+	}
+	return p.findings(), nil
+}
+
+// A program is a loaded and analyzed program.
+type program struct {
+	cfg           *Config
+	initial       []*packages.Package
+	prog          *ssa.Program
+	modules       []string
+	warnings      []string
+	sourceFuncs   []*ssa.Function
+	globals       []types.Object
+	fields        []structField
+	typeNames     []*types.TypeName
+	ifaceMethods  []ifaceMethod
+	generated     map[string]string        // maps file name to generator
+	variants      map[*ssa.Function]string // maps source function to package ID, with NoDedup
+	ignored       map[token.Position]bool
+	mains         []*ssa.Package
+	roots         []*ssa.Function
+	rootGroups    [][]*ssa.Function // roots of each executable
+	res           *rta.Result
+	reachablePosn map[token.Position]bool
+}
+
+// load loads and analyzes the program, building its call graph
+// if requested.
+func load(cfg *Config, buildCallGraph bool) (*program, error) {
+	buildCallGraph = buildCallGraph || cfg.IgnoreGeneratedCallers
+	switch cfg.Algorithm {
+	case "", "rta", "cha", "static":
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", cfg.Algorithm)
+	}
+	switch cfg.Reflection {
+	case "", "precise", "conservative":
+	default:
+		return nil, fmt.Errorf("unknown reflection treatment %q", cfg.Reflection)
+	}
+	if cfg.SwitchCases && cfg.Algorithm != "" && cfg.Algorithm != "rta" {
+		return nil, fmt.Errorf("SwitchCases requires the rta algorithm")
+	}
+	if cfg.Reachable && cfg.DynamicOnly {
+		return nil, fmt.Errorf("Reachable and DynamicOnly are mutually exclusive")
+	}
+	if cfg.NoDedup && (cfg.Reachable || cfg.DynamicOnly) {
+		return nil, fmt.Errorf("NoDedup may not be combined with Reachable or DynamicOnly")
+	}
+
+	var generatedRE *regexp.Regexp
+	if cfg.GeneratedRegexp != "" {
+		re, err := regexp.Compile(cfg.GeneratedRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generated file pattern: %v", err)
+		}
+		generatedRE = re
+	}
+	var rootRE *regexp.Regexp
+	if cfg.RootRegexp != "" {
+		re, err := regexp.Compile(cfg.RootRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root pattern: %v", err)
+		}
+		rootRE = re
+	}
+
+	// Load, parse, and type-check the complete program(s).
+	start := time.Now()
+	loadConfig := &packages.Config{
+		Dir:        cfg.Dir,
+		BuildFlags: append([]string{"-tags=" + cfg.Tags}, cfg.BuildFlags...),
+		Mode:       packages.LoadAllSyntax | packages.NeedModule,
+		Tests:      cfg.Tests,
+	}
+	if len(cfg.Env) > 0 {
+		loadConfig.Env = append(os.Environ(), cfg.Env...)
+	}
+	initial, err := packages.Load(loadConfig, cfg.Patterns...)
+	if err != nil {
+		return nil, &LoadError{Msg: fmt.Sprintf("Load: %v", err)}
+	}
+	if len(initial) == 0 {
+		return nil, &LoadError{Msg: "no packages"}
+	}
+	if errs := packageErrors(initial); len(errs) > 0 {
+		return nil, &LoadError{Msg: "packages contain errors", Packages: errs}
+	}
+	if cfg.Logf != nil {
+		npkgs := 0
+		packages.Visit(initial, nil, func(*packages.Package) { npkgs++ })
+		cfg.logf("loaded %d packages in %v", npkgs, since(start))
+	}
+
+	p := &program{
+		cfg:       cfg,
+		initial:   initial,
+		generated: make(map[string]string),
+		ignored:   make(map[token.Position]bool),
+		variants:  make(map[*ssa.Function]string),
+	}
+
+	// The modules of interest are that of the first package and,
+	// in a workspace, those of the other initial packages that
+	// belong to one of the workspace's modules.
+	seenModules := make(map[string]bool)
+	for i, pkg := range initial {
+		if mod := pkg.Module; mod != nil && (i == 0 || mod.Main) && !seenModules[mod.Path] {
+			seenModules[mod.Path] = true
+			p.modules = append(p.modules, mod.Path)
+		}
+	}
+
+	// Create SSA-form program representation
+	// and find main packages.
+	// (Build constructs the packages in parallel.)
+	start = time.Now()
+	prog, pkgs := ssautil.AllPackages(initial, ssa.InstantiateGenerics)
+	prog.Build()
+	p.prog = prog
+	cfg.logf("built SSA for %d packages in %v", len(prog.AllPackages()), since(start))
+
+	mains := ssautil.MainPackages(pkgs)
+	if len(mains) == 0 && len(cfg.Entry) == 0 && cfg.RootRegexp == "" && !cfg.Library {
+		return nil, &LoadError{Msg: "no main packages"}
+	}
+
+	// Gather all source-level functions,
+	// as the user interface is expressed in terms of them.
+	//
+	// We ignore synthetic wrappers, and nested functions. Literal
+	// functions passed as arguments to other functions are of
+	// course address-taken and there exists a dynamic call of
+	// that signature, so when they are unreachable, it is
+	// invariably because the parent is unreachable.
+	//
+	// With Vars, also gather package-level variables and constants,
+	// with Fields, the fields of named struct types, and with Types,
+	// the named types themselves.
+	//
+	// Also, record the functions whose declarations are annotated
+	// with a //deadcode:ignore comment.
+	var extraRoots []*ssa.Function // roots common to all executables
+	nasm := 0                      // number of functions implemented in assembly
+	packages.Visit(initial, nil, func(pkg *packages.Package) {
+		// Treat the functions called from assembly as roots,
+		// since those calls are invisible to the analysis.
+		implemented, referenced := asmFuncs(prog, pkg)
+		nasm += len(implemented)
+		extraRoots = append(extraRoots, referenced...)
+
+		for _, file := range pkg.Syntax {
+			decls := file.Decls
+			if isCgoInternal(pkg.Fset, file) {
+				decls = nil // not the user's code
+			}
+			for _, decl := range decls {
+				switch decl := decl.(type) {
+				case *ast.FuncDecl:
+					// Only declared functions are reported. Synthetic
+					// wrappers, such as those of methods promoted from
+					// embedded fields, have no declaration; they make
+					// the declared method they call reachable, and the
+					// Methods and DynamicOnly modes inline them by
+					// DeleteSyntheticNodes, so that the calls through
+					// a wrapper are attributed to the declared method.
+					obj := pkg.TypesInfo.Defs[decl.Name].(*types.Func)
+					fn := prog.FuncValue(obj)
+					p.sourceFuncs = append(p.sourceFuncs, fn)
+					if cfg.NoDedup {
+						p.variants[fn] = pkg.ID
+					}
+
+					if hasIgnoreDirective(decl.Doc) {
+						p.ignored[pkg.Fset.Position(decl.Name.Pos())] = true
+					}
+
+					// Treat functions exported to C by an //export
+					// directive as roots, since their callers in C
+					// are invisible to the analysis.
+					if hasExportDirective(decl) {
+						extraRoots = append(extraRoots, fn)
+					}
+
+				case *ast.GenDecl:
+					if cfg.Vars && (decl.Tok == token.VAR || decl.Tok == token.CONST) {
+						for _, spec := range decl.Specs {
+							for _, id := range spec.(*ast.ValueSpec).Names {
+								if id.Name != "_" {
+									p.globals = append(p.globals, pkg.TypesInfo.Defs[id])
+								}
+							}
+						}
+					}
+					if cfg.Fields && decl.Tok == token.TYPE {
+						for _, spec := range decl.Specs {
+							spec := spec.(*ast.TypeSpec)
+							if _, ok := spec.Type.(*ast.StructType); !ok {
+								continue // not a struct type literal
+							}
+							owner := pkg.TypesInfo.Defs[spec.Name].(*types.TypeName)
+							st := owner.Type().Underlying().(*types.Struct)
+							for i := 0; i < st.NumFields(); i++ {
+								if field := st.Field(i); !field.Embedded() && field.Name() != "_" {
+									p.fields = append(p.fields, structField{owner, field})
+								}
+							}
+						}
+					}
+					if cfg.InterfaceMethods && decl.Tok == token.TYPE {
+						for _, spec := range decl.Specs {
+							spec := spec.(*ast.TypeSpec)
+							if spec.Assign.IsValid() || spec.Name.Name == "_" {
+								continue // alias, or blank
+							}
+							p.ifaceMethods = append(p.ifaceMethods, ifaceMethods(pkg.TypesInfo.Defs[spec.Name].(*types.TypeName))...)
+						}
+					}
+					if cfg.Types && decl.Tok == token.TYPE {
+						for _, spec := range decl.Specs {
+							spec := spec.(*ast.TypeSpec)
+							if spec.Assign.IsValid() || spec.Name.Name == "_" {
+								continue // alias, or blank
+							}
+							p.typeNames = append(p.typeNames, pkg.TypesInfo.Defs[spec.Name].(*types.TypeName))
+						}
+					}
+				}
+			}
+
+			if gen, ok := generator(file, generatedRE); ok {
+				p.generated[pkg.Fset.File(file.Pos()).Name()] = gen
+			}
+
+			// Treat both sides of each //go:linkname directive as
+			// roots, since the linker may make a function callable
+			// from places invisible to the analysis.
+			for _, group := range file.Comments {
+				for _, comment := range group.List {
+					if local, target, ok := parseLinkname(comment.Text); ok {
+						if obj, ok := pkg.Types.Scope().Lookup(local).(*types.Func); ok {
+							extraRoots = append(extraRoots, prog.FuncValue(obj))
+						}
+						if target != "" {
+							if fn := lookupFunc(prog, target); fn != nil {
+								extraRoots = append(extraRoots, fn)
+							}
+						}
+					}
+				}
+			}
+		}
+	})
+
+	if nasm > 0 {
+		cfg.logf("found %d functions implemented in assembly", nasm)
+	}
+
+	// Treat the functions named by Entry as roots too,
+	// reporting all the names that cannot be resolved.
+	var errs []error
+	for _, name := range cfg.Entry {
+		fn := lookupFunc(prog, name)
+		if fn == nil {
+			errs = append(errs, fmt.Errorf("no function or method named %s", name))
+			continue
+		}
+		extraRoots = append(extraRoots, fn)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// With RootRegexp, treat the functions of the initial packages
+	// whose names match it as roots too.
+	if rootRE != nil {
+		isInitial := make(map[*ssa.Package]bool)
+		for _, pkg := range pkgs {
+			isInitial[pkg] = pkg != nil
+		}
+		nroots := 0
+		for _, fn := range p.sourceFuncs {
+			if isInitial[fn.Pkg] && fn.TypeParams().Len() == 0 && rootRE.MatchString(prettyName(fn, false)) {
+				extraRoots = append(extraRoots, fn)
+				nroots++
+			}
+		}
+		cfg.logf("found %d functions matching the root pattern", nroots)
+	}
+
+	// With Library, if there are no main packages, treat the
+	// exported API of the initial packages as roots.
+	if len(mains) == 0 && cfg.Library {
+		extraRoots = append(extraRoots, libraryRoots(prog, pkgs)...)
+	}
+
+	// Compute the reachabilty from main.
+	start = time.Now()
+	roots, rootGroups := rootsOf(mains, extraRoots)
+	res := analyze(cfg, prog, rootGroups, buildCallGraph)
+
+	// If the program calls methods chosen at run time through
+	// reflection, it may call methods the analysis considers dead.
+	// Warn about this, or, if the treatment of reflection is
+	// conservative, analyze the program again treating all exported
+	// methods as roots.
+	if caller, method := findReflectiveCall(res.Reachable); caller != nil {
+		if cfg.Reflection == "conservative" {
+			extraRoots = append(extraRoots, reflectionRoots(prog)...)
+			roots, rootGroups = rootsOf(mains, extraRoots)
+			res = analyze(cfg, prog, rootGroups, buildCallGraph)
+		} else {
+			p.warnings = append(p.warnings, fmt.Sprintf("%s calls %s; methods reported as dead may be called through reflection",
+				caller, method))
+		}
+	}
+	if cfg.Algorithm == "static" {
+		p.warnings = append(p.warnings, "results are approximate: only static calls were followed, so functions called only dynamically are reported as dead")
+	}
+	cfg.logf("analyzed %d executables, finding %d reachable functions, in %v", len(rootGroups), len(res.Reachable), since(start))
+	p.mains, p.roots, p.rootGroups, p.res = mains, roots, rootGroups, res
+
+	// Subtle: the Tests option causes us to analyze test variants
+	// such as "package p as compiled for p.test" or even "for q.test".
+	// This leads to multiple distinct ssa.Function instances that
+	// represent the same source declaration, and it is essentially
+	// impossible to discover this from the SSA representation
+	// (since it has lost the connection to go/packages.Package.ID).
+	//
+	// So, we de-duplicate such variants by position:
+	// if any one of them is live, we consider all of them live.
+	// (We use Position not Pos to avoid assuming that files common
+	// to packages "p" and "p [p.test]" were parsed only once.)
+	p.reachablePosn = make(map[token.Position]bool)
+	for fn := range res.Reachable {
+		if fn.Pos().IsValid() || fn.Name() == "init" {
+			p.reachablePosn[prog.Fset.Position(fn.Pos())] = true
+		}
+	}
+
+	// With IgnoreGeneratedCallers, treat as dead the functions that
+	// the call graph reaches only through calls from generated files.
+	// (Comparing two searches of the call graph, rather than using
+	// it alone, preserves the functions reachable only through
+	// reflection, which it lacks.)
+	if cfg.IgnoreGeneratedCallers {
+		handwritten := func(fn *ssa.Function) bool {
+			_, ok := p.generated[prog.Fset.Position(fn.Pos()).Filename]
+			return !ok
+		}
+		before := reachableVia(prog.Fset, res.CallGraph, roots, func(*ssa.Function) bool { return true })
+		after := reachableVia(prog.Fset, res.CallGraph, roots, handwritten)
+		for posn := range before {
+			if !after[posn] {
+				delete(p.reachablePosn, posn)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// findings returns the dead code of the program.
+func (p *program) findings() *Findings {
+	start := time.Now()
+	fset := p.prog.Fset
+	reachablePosn := p.reachablePosn
+
+	// With Methods, report exported methods that RTA considers
+	// reachable only because they belong to a runtime type, and thus
+	// might be called through reflection, but that are not the callee
+	// of any actual call site, static or dynamic. (Calls through
+	// reflect.Value.Call have no site.)
+	if p.cfg.Methods {
+		p.res.CallGraph.DeleteSyntheticNodes() // inline synthetic wrappers
+		calledPosn := make(map[token.Position]bool)
+		for fn, node := range p.res.CallGraph.Nodes {
+			if fn != nil && containsFunc(node.In, func(edge *callgraph.Edge) bool { return edge.Site != nil }) {
+				calledPosn[fset.Position(fn.Pos())] = true
+			}
+		}
+		for _, fn := range p.sourceFuncs {
+			if fn.Signature.Recv() != nil && token.IsExported(fn.Name()) {
+				posn := fset.Position(fn.Pos())
+				if !calledPosn[posn] {
+					delete(reachablePosn, posn)
+				}
+			}
+		}
+	}
+
+	// With DynamicOnly, report instead the reachable functions that
+	// are the callee of some dynamic call site but of no static one,
+	// by treating every other function as reachable. (Roots, and
+	// methods called only through reflection, are the callee of no
+	// call site at all.)
+	if p.cfg.DynamicOnly {
+		p.res.CallGraph.DeleteSyntheticNodes() // inline synthetic wrappers
+		staticPosn := make(map[token.Position]bool)
+		dynamicPosn := make(map[token.Position]bool)
+		for fn, node := range p.res.CallGraph.Nodes {
+			if fn == nil {
+				continue
+			}
+			posn := fset.Position(fn.Pos())
+			for _, edge := range node.In {
+				if isStaticCall(edge) {
+					staticPosn[posn] = true
+				} else if edge.Site != nil {
+					dynamicPosn[posn] = true
+				}
+			}
+		}
+		reachablePosn = make(map[token.Position]bool)
+		for _, fn := range p.sourceFuncs {
+			if posn := fset.Position(fn.Pos()); !dynamicPosn[posn] || staticPosn[posn] {
+				reachablePosn[posn] = true
+			}
+		}
+		p.globals, p.fields, p.typeNames = nil, nil, nil
+	}
+
+	// With Reachable, report instead the reachable functions,
+	// by treating the dead ones as reachable.
+	if p.cfg.Reachable {
+		deadPosn := make(map[token.Position]bool)
+		for _, fn := range p.sourceFuncs {
+			if posn := fset.Position(fn.Pos()); !reachablePosn[posn] {
+				deadPosn[posn] = true
+			}
+		}
+		reachablePosn = deadPosn
+		p.globals, p.fields, p.typeNames = nil, nil, nil
+	}
+
+	// With SwitchCases, find the type assertions and
+	// type switch cases that can never succeed.
+	var cases []deadCase
+	if p.cfg.SwitchCases && !p.cfg.DynamicOnly && !p.cfg.Reachable {
+		cases = deadCases(p.res)
+	}
+
+	// With Vars, find the package-level variables and
+	// constants that are not used by reachable code.
+	var liveGlobalPosn map[token.Position]bool
+	if p.cfg.Vars {
+		liveGlobalPosn = liveGlobals(fset, p.initial, p.res.Reachable, reachablePosn)
+	}
+
+	// With Fields, find the struct fields that are
+	// not read by reachable code.
+	var liveFieldPosn map[token.Position]bool
+	if p.cfg.Fields {
+		liveFieldPosn = liveFields(fset, p.res)
+	}
+
+	// With Types, find the named types that are
+	// not used by reachable code.
+	var liveTypePosn map[token.Position]bool
+	if p.cfg.Types {
+		liveTypePosn = liveTypes(fset, p.initial, reachablePosn)
+	}
+
+	// With InterfaceMethods, find the methods of the interface
+	// types used by reachable code that are never called.
+	var uncalled []ifaceMethod
+	if p.cfg.InterfaceMethods && !p.cfg.DynamicOnly && !p.cfg.Reachable {
+		used := liveTypePosn
+		if used == nil {
+			used = liveTypes(fset, p.initial, reachablePosn)
+		}
+		var methods []ifaceMethod
+		for _, m := range p.ifaceMethods {
+			if used[fset.Position(m.owner.Pos())] {
+				methods = append(methods, m)
+			}
+		}
+		uncalled = uncalledMethods(p.res, methods)
+	}
+
+	// With BlankImports, find the imports of packages
+	// solely for initialization that has no effect.
+	var stale []blankImport
+	if p.cfg.BlankImports && !p.cfg.DynamicOnly && !p.cfg.Reachable {
+		stale = staleBlankImports(p.prog, p.initial)
+	}
+
+	// Record the unreachable functions, and with Vars, Fields, and
+	// Types, the unused variables, constants, fields, and types.
+	found := &Findings{
+		Modules:  p.modules,
+		Warnings: p.warnings,
+		NumFuncs: make(map[string]int),
+	}
+	byPkgPath := make(map[string]*Package)
+	addDead := func(pkg *types.Package, posn token.Position, f Function) {
+		f.Position = posn
+		f.Generator, f.Generated = p.generated[posn.Filename]
+		f.Ignored = p.ignored[posn]
+		dead, ok := byPkgPath[pkg.Path()]
+		if !ok {
+			dead = &Package{Name: pkg.Name(), Path: pkg.Path()}
+			byPkgPath[pkg.Path()] = dead
+		}
+		dead.Funcs = append(dead.Funcs, f)
+	}
+	seen := make(map[token.Position]bool)
+	for _, fn := range p.sourceFuncs {
+		if posn := fset.Position(fn.Pos()); !seen[posn] {
+			seen[posn] = true // suppress dups with same pos
+			found.NumFuncs[fn.Pkg.Pkg.Path()]++
+		}
+	}
+	seenFiles := make(map[string]bool)
+	packages.Visit(p.initial, nil, func(pkg *packages.Package) {
+		for _, file := range pkg.GoFiles {
+			if !seenFiles[file] {
+				seenFiles[file] = true
+				found.Files = append(found.Files, file)
+			}
+		}
+	})
+	sort.Strings(found.Files)
+
+	// A dead generic function may have instantiations, created for
+	// calls within dead code. Record their type arguments, so that
+	// the report shows that it was exercised only by dead code. (An
+	// instantiation has the position of the generic declaration, so
+	// it is the declaration that is reported.)
+	var typeArgs map[token.Position][]string
+	if containsFunc(p.sourceFuncs, func(fn *ssa.Function) bool {
+		return fn.TypeParams().Len() > 0 && !reachablePosn[fset.Position(fn.Pos())]
+	}) {
+		typeArgs = make(map[token.Position][]string)
+		for fn := range ssautil.AllFunctions(p.prog) {
+			if fn.Parent() != nil || fn.Origin() == nil {
+				continue // not an instantiation of a declared function
+			}
+			var args []string
+			for _, t := range fn.TypeArgs() {
+				args = append(args, types.TypeString(t, types.RelativeTo(fn.Origin().Pkg.Pkg)))
+			}
+			posn := fset.Position(fn.Pos())
+			inst := "[" + strings.Join(args, ", ") + "]"
+			if !containsFunc(typeArgs[posn], func(s string) bool { return s == inst }) {
+				typeArgs[posn] = append(typeArgs[posn], inst) // (suppress dups of test variants)
+			}
+		}
+		for _, args := range typeArgs {
+			sort.Strings(args)
+		}
+	}
+
+	// With NoDedup, a function is dead if it is unreachable itself,
+	// even though another variant of it at the same position is not.
+	var live map[*ssa.Function]bool
+	if p.cfg.NoDedup {
+		live = make(map[*ssa.Function]bool)
+		for fn := range p.res.Reachable {
+			if fn.Origin() != nil {
+				fn = fn.Origin() // generic function of instantiation
+			}
+			live[fn] = true
+		}
+	}
+
+	for _, fn := range p.sourceFuncs {
+		posn := fset.Position(fn.Pos())
+
+		if !reachablePosn[posn] || (live != nil && !live[fn]) {
+			if live == nil {
+				reachablePosn[posn] = true // suppress dups with same pos
+			}
+
+			f := Function{
+				Kind:      "func",
+				Name:      prettyName(fn, false),
+				Exported:  fn.Object().Exported(),
+				IsMethod:  fn.Signature.Recv() != nil,
+				Signature: types.TypeString(fn.Signature, types.RelativeTo(fn.Pkg.Pkg)),
+				Lines:     lineCount(fset, fn),
+			}
+			if recv := fn.Signature.Recv(); recv != nil {
+				f.Recv = types.TypeString(recv.Type(), types.RelativeTo(fn.Pkg.Pkg))
+			}
+			if fn.TypeParams().Len() > 0 {
+				f.TypeArgs = typeArgs[posn]
+			}
+			if syntax := fn.Syntax(); syntax != nil {
+				f.End = fset.Position(syntax.End())
+			}
+			f.Variant = p.variants[fn]
+			addDead(fn.Pkg.Pkg, posn, f)
+		}
+	}
+	for _, obj := range p.globals {
+		posn := fset.Position(obj.Pos())
+
+		if !liveGlobalPosn[posn] {
+			liveGlobalPosn[posn] = true // suppress dups with same pos
+
+			_, isConst := obj.(*types.Const)
+			addDead(obj.Pkg(), posn, Function{
+				Kind:     cond(isConst, "const", "var"),
+				Name:     obj.Name(),
+				Exported: obj.Exported(),
+			})
+		}
+	}
+	for _, f := range p.fields {
+		posn := fset.Position(f.field.Pos())
+
+		if !liveFieldPosn[posn] {
+			liveFieldPosn[posn] = true // suppress dups with same pos
+
+			addDead(f.field.Pkg(), posn, Function{
+				Kind:     "field",
+				Name:     f.owner.Name() + "." + f.field.Name(),
+				Exported: f.field.Exported(),
+			})
+		}
+	}
+	for _, obj := range p.typeNames {
+		posn := fset.Position(obj.Pos())
+
+		if !liveTypePosn[posn] {
+			liveTypePosn[posn] = true // suppress dups with same pos
+
+			addDead(obj.Pkg(), posn, Function{
+				Kind:     "type",
+				Name:     obj.Name(),
+				Exported: obj.Exported(),
+			})
+		}
+	}
+	seenMethods := make(map[token.Position]bool)
+	for _, m := range uncalled {
+		posn := fset.Position(m.method.Pos())
+
+		if !seenMethods[posn] {
+			seenMethods[posn] = true // suppress dups with same pos
+
+			addDead(m.method.Pkg(), posn, Function{
+				Kind:      "imethod",
+				Name:      m.owner.Name() + "." + m.method.Name(),
+				Exported:  m.method.Exported(),
+				Signature: types.TypeString(m.method.Type(), types.RelativeTo(m.method.Pkg())),
+			})
+		}
+	}
+	seenImports := make(map[token.Position]bool)
+	for _, imp := range stale {
+		posn := fset.Position(imp.spec.Pos())
+
+		if !seenImports[posn] {
+			seenImports[posn] = true // suppress dups with same pos
+
+			addDead(imp.pkg.Types, posn, Function{
+				Kind: "import",
+				Name: imp.path,
+			})
+		}
+	}
+	type caseKey struct {
+		posn token.Position
+		name string
+	}
+	seenCases := make(map[caseKey]bool)
+	for _, c := range cases {
+		posn := fset.Position(c.assert.Pos())
+		pkg := c.fn.Pkg.Pkg
+		name := prettyName(c.fn, false) + ".(" + types.TypeString(c.assert.AssertedType, types.RelativeTo(pkg)) + ")"
+
+		if k := (caseKey{posn, name}); !seenCases[k] {
+			seenCases[k] = true // suppress dups with same pos
+
+			addDead(pkg, posn, Function{
+				Kind: "case",
+				Name: name,
+			})
+		}
+	}
+
+	// Sort the packages by path, and their functions by position,
+	// then kind and name, so that the order is deterministic even if
+	// several share a position.
+	ndead := 0
+	pkgpaths := keys(byPkgPath)
+	sort.Strings(pkgpaths)
+	for _, pkgpath := range pkgpaths {
+		pkg := byPkgPath[pkgpath]
+		sort.Slice(pkg.Funcs, func(i, j int) bool {
+			x, y := pkg.Funcs[i], pkg.Funcs[j]
+			if x.Position.Filename != y.Position.Filename {
+				return x.Position.Filename < y.Position.Filename
+			}
+			if x.Position.Offset != y.Position.Offset {
+				return x.Position.Offset < y.Position.Offset
+			}
+			if x.Kind != y.Kind {
+				return x.Kind < y.Kind
+			}
+			return x.Name < y.Name
+		})
+		found.Packages = append(found.Packages, *pkg)
+		ndead += len(pkg.Funcs)
+	}
+	p.cfg.logf("found %d dead objects among %d functions in %v", ndead, len(p.sourceFuncs), since(start))
+	return found
+}
+
+// packageErrors returns the errors of the packages and their
+// dependencies, grouped by package.
+func packageErrors(initial []*packages.Package) []PackageErrors {
+	var result []PackageErrors
+	seenModules := make(map[*packages.Module]bool)
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		var errs []string
+		if mod := p.Module; mod != nil && mod.Error != nil && !seenModules[mod] {
+			seenModules[mod] = true
+			errs = append(errs, mod.Error.Err)
+		}
+		for _, err := range p.Errors {
+			errs = append(errs, err.Error())
+		}
+		if len(errs) > 0 {
+			result = append(result, PackageErrors{Path: p.PkgPath, Errors: errs})
+		}
+	})
+	return result
+}
+
+// logf logs a progress message, if requested.
+func (cfg *Config) logf(format string, args ...any) {
+	if cfg.Logf != nil {
+		cfg.Logf(format, args...)
+	}
+}
+
+// since returns the time elapsed since start, rounded for logging.
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Millisecond)
+}
+
+// libraryRoots returns the roots of a library consisting of the
+// specified packages: their init functions, exported functions, and
+// exported methods. Generic functions are omitted, since it is not
+// known how they will be instantiated.
+func libraryRoots(prog *ssa.Program, pkgs []*ssa.Package) []*ssa.Function {
+	var roots []*ssa.Function
+	for _, pkg := range pkgs {
+		roots = append(roots, pkg.Func("init"))
+		var names []string
+		for name, mem := range pkg.Members {
+			if fn, ok := mem.(*ssa.Function); ok && token.IsExported(name) && fn.TypeParams().Len() == 0 {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			roots = append(roots, pkg.Func(name))
+		}
+	}
+	return append(roots, exportedMethods(prog, pkgs)...)
+}
+
+// rootsOf returns the roots of each executable: the init and main
+// functions of its main package, plus the extra roots. It also
+// returns the union of all roots. If there are no main packages,
+// the extra roots form a single group.
+func rootsOf(mains []*ssa.Package, extraRoots []*ssa.Function) (roots []*ssa.Function, rootGroups [][]*ssa.Function) {
+	if len(mains) == 0 {
+		return extraRoots, [][]*ssa.Function{extraRoots}
+	}
+	for _, main := range mains {
+		group := append([]*ssa.Function{main.Func("init"), main.Func("main")}, extraRoots...)
+		rootGroups = append(rootGroups, group)
+		roots = append(roots, group[:2]...)
+	}
+	roots = append(roots, extraRoots...)
+	return roots, rootGroups
+}
+
+// analyze computes the set of functions reachable from the roots of
+// each group, and, if buildCallGraph, the call graph, using the
+// algorithm specified by the configuration.
+//
+// For uniformity, the results of both algorithms are expressed as an
+// [rta.Result]; however, the RuntimeTypes field is populated only by RTA.
+func analyze(cfg *Config, prog *ssa.Program, rootGroups [][]*ssa.Function, buildCallGraph bool) *rta.Result {
+	if cfg.Algorithm != "cha" && cfg.Algorithm != "static" {
+		// Each group of roots is a separate executable, so
+		// we analyze them independently (and in parallel)
+		// and combine the results.
+		parallel := cfg.Parallel
+		if parallel <= 0 {
+			parallel = runtime.GOMAXPROCS(0)
+		}
+		results := make([]*rta.Result, len(rootGroups))
+		var wg sync.WaitGroup
+		limit := make(chan struct{}, parallel) // counting semaphore
+		for i, roots := range rootGroups {
+			wg.Add(1)
+			limit <- struct{}{}
+			go func(i int, roots []*ssa.Function) {
+				defer func() { <-limit; wg.Done() }()
+				results[i] = rta.Analyze(roots, buildCallGraph)
+			}(i, roots)
+		}
+		wg.Wait()
+		if len(results) == 1 {
+			return results[0]
+		}
+		return mergeResults(results)
+	}
+
+	// CHA (or the static algorithm) computes a call graph of the
+	// whole program, from which we compute the part reachable from
+	// the roots, breadth first so that, with MaxDepth, each function
+	// is first visited at its least depth.
+	// The result does not depend on how the roots are grouped.
+	var cg *callgraph.Graph
+	if cfg.Algorithm == "static" {
+		cg = static.CallGraph(prog)
+	} else {
+		cg = cha.CallGraph(prog)
+	}
+	res := &rta.Result{Reachable: make(map[*ssa.Function]struct{ AddrTaken bool })}
+	type item struct {
+		node  *callgraph.Node
+		depth int // number of calls from a root
+	}
+	var queue []item
+	for _, roots := range rootGroups {
+		for _, root := range roots {
+			if node := cg.Nodes[root]; node != nil {
+				queue = append(queue, item{node, 0})
+			}
+		}
+	}
+	for len(queue) > 0 {
+		it := queue[0]
+		queue = queue[1:]
+		if _, ok := res.Reachable[it.node.Func]; ok {
+			continue
+		}
+		res.Reachable[it.node.Func] = struct{ AddrTaken bool }{}
+		if cfg.Algorithm == "static" && cfg.MaxDepth > 0 && it.depth >= cfg.MaxDepth {
+			continue
+		}
+		for _, edge := range it.node.Out {
+			queue = append(queue, item{edge.Callee, it.depth + 1})
+		}
+	}
+	if buildCallGraph {
+		res.CallGraph = cg
+	}
+	return res
+}
+
+// mergeResults returns the union of several RTA results.
+// A function is reachable (or address-taken) if it is so in any
+// result, and the call graph contains the edges of all of them.
+func mergeResults(results []*rta.Result) *rta.Result {
+	merged := &rta.Result{Reachable: make(map[*ssa.Function]struct{ AddrTaken bool })}
+	type edgeKey struct {
+		caller, callee *ssa.Function
+		site           ssa.CallInstruction
+	}
+	seen := make(map[edgeKey]bool)
+	for _, res := range results {
+		for fn, r := range res.Reachable {
+			prev := merged.Reachable[fn]
+			prev.AddrTaken = prev.AddrTaken || r.AddrTaken
+			merged.Reachable[fn] = prev
+		}
+
+		// The value of each RuntimeTypes entry records
+		// whether its method set was not computed.
+		res.RuntimeTypes.Iterate(func(T types.Type, v any) {
+			skip := v.(bool)
+			if prev, ok := merged.RuntimeTypes.At(T).(bool); ok {
+				skip = skip && prev
+			}
+			merged.RuntimeTypes.Set(T, skip)
+		})
+
+		if res.CallGraph != nil {
+			if merged.CallGraph == nil {
+				merged.CallGraph = callgraph.New(res.CallGraph.Root.Func)
+			}
+			cg := merged.CallGraph
+			for fn, node := range res.CallGraph.Nodes {
+				cg.CreateNode(fn)
+				for _, e := range node.Out {
+					k := edgeKey{fn, e.Callee.Func, e.Site}
+					if !seen[k] {
+						seen[k] = true
+						callgraph.AddEdge(cg.CreateNode(fn), e.Site, cg.CreateNode(e.Callee.Func))
+					}
+				}
+			}
+		}
+	}
+	return merged
+}
+
+// lineCount returns the number of source lines spanned by the
+// declaration of fn, from its name to the end of its body,
+// or zero if fn has no syntax.
+func lineCount(fset *token.FileSet, fn *ssa.Function) int {
+	syntax := fn.Syntax()
+	if syntax == nil {
+		return 0
+	}
+	return fset.Position(syntax.End()).Line - fset.Position(fn.Pos()).Line + 1
+}
+
+func cond[T any](cond bool, t, f T) T {
+	if cond {
+		return t
+	} else {
+		return f
+	}
+}
+
+// -- from the future --
+
+// TODO(adonovan): use go1.22's slices and maps packages.
+
+func containsFunc[S ~[]E, E any](s S, f func(E) bool) bool {
+	return indexFunc(s, f) >= 0
+}
+
+func indexFunc[S ~[]E, E any](s S, f func(E) bool) int {
+	for i := range s {
+		if f(s[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func index[S ~[]E, E comparable](s S, v E) int {
+	for i := range s {
+		if v == s[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+func reverse[S ~[]E, E any](s S) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+func keys[M ~map[K]V, K comparable, V any](m M) []K {
+	r := make([]K, 0, len(m))
+	for k := range m {
+		r = append(r, k)
+	}
+	return r
+}
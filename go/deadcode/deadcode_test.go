@@ -0,0 +1,367 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/deadcode"
+	"golang.org/x/tools/internal/testenv"
+)
+
+const src = `
+package main
+
+func main() { live() }
+
+func live() {}
+
+func dead() {}
+
+//deadcode:ignore
+func ignored() {}
+`
+
+const generatedSrc = `// Code generated by hand. DO NOT EDIT.
+
+package main
+
+func generated() {}
+`
+
+func TestAnalyze(t *testing.T) {
+	testenv.NeedsGoPackages(t)
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":  "module example.com\n\ngo 1.18\n",
+		"main.go": src,
+		"gen.go":  generatedSrc,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkgs, err := deadcode.Analyze(deadcode.Config{Dir: dir, Patterns: []string{"./..."}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pkg := range pkgs {
+		for _, fn := range pkg.Funcs {
+			got = append(got, pkg.Path+"."+fn.Name)
+		}
+	}
+	if want := "example.com.dead"; strings.Join(got, " ") != want {
+		t.Errorf("Analyze reported %q, want [%s]", got, want)
+	}
+}
+
+// program is a module with two packages, for the tests of the
+// functions other than Analyze.
+var program = map[string]string{
+	"go.mod": "module example.com\n\ngo 1.18\n",
+	"main.go": `
+package main
+
+import "example.com/lib"
+
+type I interface{ M() }
+
+type T int
+
+func (T) M() {}
+
+func main() {
+	live()
+	var i I = T(0)
+	i.M()
+	lib.Used()
+}
+
+func live() { helper() }
+
+func helper() {}
+
+func dead() {}
+
+//deadcode:ignore
+func ignored() {}
+`,
+	"gen.go": generatedSrc,
+	"lib/lib.go": `
+package lib
+
+func Used() {}
+
+func Unused() {}
+`,
+}
+
+// writeProgram writes the files of the program to a temporary
+// directory, and returns a Config for analyzing it.
+func writeProgram(t *testing.T) deadcode.Config {
+	testenv.NeedsGoPackages(t)
+
+	dir := t.TempDir()
+	for name, content := range program {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return deadcode.Config{Dir: dir, Patterns: []string{"./..."}}
+}
+
+// names returns the package-qualified names of the dead functions.
+func names(pkgs []deadcode.Package) string {
+	var names []string
+	for _, pkg := range pkgs {
+		for _, fn := range pkg.Funcs {
+			names = append(names, pkg.Path+"."+fn.Name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	cfg := writeProgram(t)
+
+	cfg.Filter = []string{"("}
+	if _, err := deadcode.Analyze(cfg); err == nil || !strings.Contains(err.Error(), "invalid filter") {
+		t.Errorf("Analyze with invalid filter returned error %v", err)
+	}
+
+	cfg.Filter = nil
+	cfg.Patterns = []string{"./lib"}
+	_, err := deadcode.Analyze(cfg)
+	if loadErr, ok := err.(*deadcode.LoadError); !ok || loadErr.Msg != "no main packages" {
+		t.Errorf("Analyze of library returned error %v, want LoadError: no main packages", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	cfg := writeProgram(t)
+
+	found, err := deadcode.Find(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(found.Modules, " "); got != "example.com" {
+		t.Errorf("Modules = %s, want example.com", got)
+	}
+	// Unlike Analyze, Find reports ignored and generated functions.
+	// Functions are in order of position.
+	if got, want := names(found.Packages), "example.com.generated example.com.dead example.com.ignored example.com/lib.Unused"; got != want {
+		t.Errorf("Find reported [%s], want [%s]", got, want)
+	}
+	for _, pkg := range found.Packages {
+		for _, fn := range pkg.Funcs {
+			if fn.Ignored != (fn.Name == "ignored") || fn.Generated != (fn.Name == "generated") {
+				t.Errorf("%s: Ignored = %t, Generated = %t", fn.Name, fn.Ignored, fn.Generated)
+			}
+		}
+	}
+
+	cfg.Reachable = true
+	found, err = deadcode.Find(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(found.Packages), "example.com.T.M example.com.main example.com.live example.com.helper example.com/lib.Used"; got != want {
+		t.Errorf("Find with Reachable reported [%s], want [%s]", got, want)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	cfg := writeProgram(t)
+
+	for _, test := range []struct {
+		cfg  func(*deadcode.Config)
+		want string
+	}{
+		{func(cfg *deadcode.Config) { cfg.Algorithm = "vta" }, `unknown algorithm "vta"`},
+		{func(cfg *deadcode.Config) { cfg.Reflection = "lax" }, `unknown reflection treatment "lax"`},
+		{func(cfg *deadcode.Config) { cfg.Algorithm, cfg.SwitchCases = "cha", true }, "SwitchCases requires the rta algorithm"},
+		{func(cfg *deadcode.Config) { cfg.Reachable, cfg.DynamicOnly = true, true }, "Reachable and DynamicOnly are mutually exclusive"},
+		{func(cfg *deadcode.Config) { cfg.NoDedup, cfg.Reachable = true, true }, "NoDedup may not be combined with Reachable or DynamicOnly"},
+		{func(cfg *deadcode.Config) { cfg.RootRegexp = "(" }, "invalid root pattern"},
+		{func(cfg *deadcode.Config) { cfg.Entry = []string{"example.com.nonesuch"} }, "no function or method named example.com.nonesuch"},
+		{func(cfg *deadcode.Config) { cfg.Patterns = []string{"./nonesuch"} }, "packages contain errors"},
+	} {
+		cfg := cfg
+		test.cfg(&cfg)
+		if _, err := deadcode.Find(cfg); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Find returned error %v, want %q", err, test.want)
+		}
+	}
+}
+
+func TestWhyLive(t *testing.T) {
+	cfg := writeProgram(t)
+
+	edges, err := deadcode.WhyLive(cfg, "example.com.helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var path []string
+	for _, edge := range edges {
+		path = append(path, edge.Caller+" -> "+edge.Callee)
+		if edge.Dynamic {
+			t.Errorf("call %s -> %s is dynamic", edge.Caller, edge.Callee)
+		}
+	}
+	if got, want := strings.Join(path, ", "), "example.com.main -> example.com.live, example.com.live -> example.com.helper"; got != want {
+		t.Errorf("WhyLive returned [%s], want [%s]", got, want)
+	}
+
+	for name, want := range map[string]string{
+		"example.com.nonesuch": `function "example.com.nonesuch" not found in program`,
+		"example.com.dead":     "function example.com.dead is dead code",
+		"example.com.main":     "is a root",
+	} {
+		if _, err := deadcode.WhyLive(cfg, name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("WhyLive(%s) returned error %v, want %q", name, err, want)
+		}
+	}
+}
+
+func TestIfRemoved(t *testing.T) {
+	cfg := writeProgram(t)
+
+	found, err := deadcode.IfRemoved(cfg, "example.com.live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(found.Packages); got != "example.com.helper" {
+		t.Errorf("IfRemoved reported [%s], want [example.com.helper]", got)
+	}
+
+	for name, want := range map[string]string{
+		"example.com.nonesuch": `function "example.com.nonesuch" not found in program`,
+		"example.com.dead":     "function example.com.dead is dead code already",
+	} {
+		if _, err := deadcode.IfRemoved(cfg, name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("IfRemoved(%s) returned error %v, want %q", name, err, want)
+		}
+	}
+}
+
+func TestExplainPackage(t *testing.T) {
+	cfg := writeProgram(t)
+
+	statuses, err := deadcode.ExplainPackage(cfg, "example.com/lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("ExplainPackage returned %d statuses, want 2: %+v", len(statuses), statuses)
+	}
+	if s := statuses[0]; s.Name != "Used" || !s.Reachable || s.Root || s.Caller != "example.com.main" || !s.CallSite.IsValid() {
+		t.Errorf("status of Used = %+v, want reachable, called by example.com.main", s)
+	}
+	if s := statuses[1]; s.Name != "Unused" || s.Reachable || s.Caller != "" {
+		t.Errorf("status of Unused = %+v, want unreachable", s)
+	}
+
+	if _, err := deadcode.ExplainPackage(cfg, "example.com/nonesuch"); err == nil || !strings.Contains(err.Error(), "no functions of package example.com/nonesuch") {
+		t.Errorf("ExplainPackage of unknown package returned error %v", err)
+	}
+}
+
+func TestReachableFrom(t *testing.T) {
+	cfg := writeProgram(t)
+
+	reach, err := deadcode.ReachableFrom(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pkg := range reach.Packages {
+		if strings.HasPrefix(pkg.Path, "example.com") {
+			got = append(got, pkg.Path+" <- "+strings.Join(pkg.Mains, " "))
+		}
+	}
+	if want := "example.com <- example.com, example.com/lib <- example.com"; strings.Join(got, ", ") != want {
+		t.Errorf("ReachableFrom returned [%s], want [%s]", strings.Join(got, ", "), want)
+	}
+
+	cfg.Patterns, cfg.Library = []string{"./lib"}, true
+	if _, err := deadcode.ReachableFrom(cfg); err == nil || err.Error() != "no main packages" {
+		t.Errorf("ReachableFrom of library returned error %v, want: no main packages", err)
+	}
+}
+
+func TestRoots(t *testing.T) {
+	cfg := writeProgram(t)
+	cfg.Entry = []string{"example.com/lib.Unused"}
+
+	roots, err := deadcode.Roots(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, root := range roots {
+		got = append(got, root.Name)
+	}
+	if want := "example.com.init example.com.main example.com/lib.Unused"; strings.Join(got, " ") != want {
+		t.Errorf("Roots returned [%s], want [%s]", strings.Join(got, " "), want)
+	}
+	if !roots[1].Position.IsValid() {
+		t.Errorf("root main has no position")
+	}
+
+	cfg.Entry = []string{"example.com.nonesuch"}
+	if _, err := deadcode.Roots(cfg); err == nil || !strings.Contains(err.Error(), "no function or method named example.com.nonesuch") {
+		t.Errorf("Roots with unknown entry returned error %v", err)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	cfg := writeProgram(t)
+
+	var buf bytes.Buffer
+	if err := deadcode.WriteDOT(&buf, cfg, 0); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"digraph",
+		`"example.com.main" -> "example.com.live"`,
+		`"example.com.live" -> "example.com.helper"`,
+		`"example.com.main" -> "example.com/lib.Used"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteDOT output lacks %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "example.com.dead") {
+		t.Errorf("WriteDOT output contains dead function:\n%s", got)
+	}
+
+	// With maxDepth, only the calls of the roots are shown.
+	buf.Reset()
+	if err := deadcode.WriteDOT(&buf, cfg, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, `"example.com.main" -> "example.com.live"`) || strings.Contains(got, "example.com.helper") {
+		t.Errorf("WriteDOT with maxDepth=1 output:\n%s", got)
+	}
+
+	cfg.Patterns = []string{"./lib"}
+	if err := deadcode.WriteDOT(&buf, cfg, 0); err == nil {
+		t.Errorf("WriteDOT of library succeeded")
+	}
+}
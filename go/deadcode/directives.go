@@ -0,0 +1,172 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// This file defines the handling of the comments, such as directives,
+// that affect the analysis.
+
+// parseLinkname parses a "//go:linkname localname [importpath.name]"
+// directive, returning the local and (optional) target names.
+func parseLinkname(comment string) (local, target string, ok bool) {
+	rest, ok := strings.CutPrefix(comment, "//go:linkname ")
+	if !ok {
+		return "", "", false
+	}
+	switch fields := strings.Fields(rest); len(fields) {
+	case 1:
+		return fields[0], "", true
+	case 2:
+		return fields[0], fields[1], true
+	}
+	return "", "", false
+}
+
+// lookupFunc returns the function or method of the program denoted by
+// a fully qualified name such as "example.com/pkg.Func",
+// "example.com/pkg.Type.Method", or "example.com/pkg.(*Type).Method",
+// or nil if there is no such function.
+func lookupFunc(prog *ssa.Program, name string) *ssa.Function {
+	// The package path ends at the first dot after the last slash.
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot < 0 {
+		return nil
+	}
+	pkgpath, member := name[:slash+1+dot], name[slash+1+dot+1:]
+	pkg := prog.ImportedPackage(pkgpath)
+	if pkg == nil {
+		return nil
+	}
+
+	recv, method, isMethod := strings.Cut(member, ".")
+	if !isMethod {
+		if obj, ok := pkg.Pkg.Scope().Lookup(member).(*types.Func); ok {
+			return prog.FuncValue(obj)
+		}
+		return nil
+	}
+
+	// method: "T.M" or "(*T).M"
+	ptr := false
+	if strings.HasPrefix(recv, "(*") && strings.HasSuffix(recv, ")") {
+		recv, ptr = recv[len("(*"):len(recv)-len(")")], true
+	}
+	tname, ok := pkg.Pkg.Scope().Lookup(recv).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	var T types.Type = tname.Type()
+	if ptr {
+		T = types.NewPointer(T)
+	}
+	obj, _, _ := types.LookupFieldOrMethod(T, true, pkg.Pkg, method)
+	if fn, ok := obj.(*types.Func); ok {
+		return prog.FuncValue(fn)
+	}
+	return nil
+}
+
+// hasIgnoreDirective reports whether the doc comment contains
+// a //deadcode:ignore directive, optionally followed by an
+// explanation.
+func hasIgnoreDirective(doc *ast.CommentGroup) bool {
+	if doc != nil {
+		for _, comment := range doc.List {
+			if rest, ok := strings.CutPrefix(comment.Text, "//deadcode:ignore"); ok &&
+				(rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasExportDirective reports whether the function declaration is
+// preceded by a cgo "//export Name" directive, which makes the function
+// callable from C.
+func hasExportDirective(decl *ast.FuncDecl) bool {
+	if decl.Recv == nil && decl.Doc != nil {
+		for _, comment := range decl.Doc.List {
+			if rest, ok := strings.CutPrefix(comment.Text, "//export "); ok &&
+				strings.TrimSpace(rest) == decl.Name.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCgoInternal reports whether the file was generated by cgo for its
+// own use, such as _cgo_gotypes.go, which declares the wrappers of C
+// functions and of Go functions exported to C. By contrast, cgo's
+// translation of each of the user's files refers, through a //line
+// directive, to the original file.
+func isCgoInternal(fset *token.FileSet, file *ast.File) bool {
+	gen, ok := generator(file, nil)
+	return ok && gen == "cmd/cgo" &&
+		fset.Position(file.Package).Filename == fset.File(file.Package).Name()
+}
+
+// generator reports whether the file was generated by a program,
+// not handwritten, by detecting the special comment described
+// at https://go.dev/s/generatedcode. If so, it also returns the
+// name of the program, such as "stringer" for the comment
+// "// Code generated by stringer. DO NOT EDIT."; the name may be
+// empty if the comment does not follow this usual form. Variations in
+// the punctuation at the end, such as "DO NOT EDIT!", are accepted.
+//
+// If re is not nil, the file is also considered generated (by an
+// unknown program) if the text of a comment before its package
+// declaration matches re. Likewise, on Go 1.21 and later, if
+// [ast.IsGenerated] reports that it is, so that the tool agrees with
+// the standard library even if it classifies some comment differently;
+// the name of the program is then unknown.
+//
+// The syntax tree must have been parsed with the ParseComments flag.
+func generator(file *ast.File, re *regexp.Regexp) (string, bool) {
+	matched := false
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if comment.Pos() > file.Package {
+				break // after package declaration
+			}
+			// opt: check Contains first to avoid unnecessary array allocation in Split.
+			const prefix = "// Code generated "
+			if strings.Contains(comment.Text, prefix) {
+				for _, line := range strings.Split(comment.Text, "\n") {
+					if rest, ok := strings.CutPrefix(line, prefix); ok {
+						// Some generators vary the punctuation,
+						// as in "DO NOT EDIT!", so ignore it.
+						rest = strings.TrimRightFunc(rest, func(r rune) bool {
+							return unicode.IsPunct(r) || unicode.IsSpace(r)
+						})
+						if gen, ok := strings.CutSuffix(rest, " DO NOT EDIT"); ok {
+							gen = strings.TrimPrefix(gen, "by ")
+							gen = strings.TrimRight(gen, ".;") // e.g. "cmd/cgo;"
+							return gen, true
+						}
+					}
+				}
+			}
+			if re != nil && re.MatchString(comment.Text) {
+				matched = true
+			}
+		}
+	}
+	return "", matched || isGeneratedStd(file)
+}
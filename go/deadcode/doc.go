@@ -0,0 +1,33 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package deadcode reports unreachable functions in Go programs.
+//
+// It is the analysis behind the deadcode command
+// (golang.org/x/tools/cmd/deadcode), whose documentation describes
+// the algorithm and its limitations. Programs such as editors and
+// linters may call [Analyze] to obtain the same results as the
+// command without running it and parsing its output:
+//
+//	pkgs, err := deadcode.Analyze(deadcode.Config{Patterns: []string{"./..."}})
+//	if err != nil {
+//		return err
+//	}
+//	for _, pkg := range pkgs {
+//		for _, fn := range pkg.Funcs {
+//			fmt.Printf("%s: unreachable %s: %s\n", fn.Position, fn.Kind, fn.Name)
+//		}
+//	}
+//
+// The lower-level [Find] function reports all the dead code of the
+// program, before filtering. The [WhyLive] and [WriteDOT] functions
+// explain why functions are live, by reporting the calls that reach
+// them, [IfRemoved] reports the functions that deleting a function
+// would make dead, [ReachableFrom] reports which executables reach
+// each package, [ExplainPackage] reports the reachability of each
+// function of a package, and [Roots] reports the roots of the
+// analysis.
+//
+// This package requires go1.20 or later.
+package deadcode
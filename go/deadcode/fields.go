@@ -0,0 +1,84 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A structField is a field of a named struct type, for [Config.Fields].
+type structField struct {
+	owner *types.TypeName
+	field *types.Var
+}
+
+// liveFields returns the positions of the struct fields that are read
+// by reachable code, for [Config.Fields].
+//
+// A field is read if a reachable function loads its value, or takes
+// its address for any purpose other than storing to it. In addition,
+// every exported field of a struct type needed at run time is
+// considered read, since it may be read through reflection (for
+// example, by encoding/json).
+//
+// The result is approximate: it ignores implicit reads of all
+// fields, such as comparisons and copies of whole struct values, and
+// reads of unexported fields through reflection (for example, by fmt).
+func liveFields(fset *token.FileSet, res *rta.Result) map[token.Position]bool {
+	live := make(map[token.Position]bool)
+	read := func(T types.Type, index int) {
+		if st, ok := T.Underlying().(*types.Struct); ok {
+			live[fset.Position(st.Field(index).Origin().Pos())] = true
+		}
+	}
+
+	for fn := range res.Reachable {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case *ssa.Field:
+					read(instr.X.Type(), instr.Field)
+
+				case *ssa.FieldAddr:
+					if ptr, ok := instr.X.Type().Underlying().(*types.Pointer); ok && !onlyStored(instr) {
+						read(ptr.Elem(), instr.Field)
+					}
+				}
+			}
+		}
+	}
+
+	res.RuntimeTypes.Iterate(func(T types.Type, _ any) {
+		if ptr, ok := T.(*types.Pointer); ok {
+			T = ptr.Elem()
+		}
+		if st, ok := T.Underlying().(*types.Struct); ok {
+			for i := 0; i < st.NumFields(); i++ {
+				if field := st.Field(i); field.Exported() {
+					live[fset.Position(field.Origin().Pos())] = true
+				}
+			}
+		}
+	})
+
+	return live
+}
+
+// onlyStored reports whether the address is used only to store
+// values, and never to load them.
+func onlyStored(addr ssa.Value) bool {
+	for _, instr := range *addr.Referrers() {
+		if store, ok := instr.(*ssa.Store); !ok || store.Addr != addr {
+			return false
+		}
+	}
+	return true
+}
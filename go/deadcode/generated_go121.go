@@ -0,0 +1,13 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package deadcode
+
+import "go/ast"
+
+func isGeneratedStd(file *ast.File) bool {
+	return ast.IsGenerated(file)
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/ssa"
+)
+
+// An ifaceMethod is a method declared by a named interface type,
+// for [Config.InterfaceMethods].
+type ifaceMethod struct {
+	owner  *types.TypeName
+	method *types.Func
+}
+
+// ifaceMethods returns the methods declared explicitly by the named
+// interface type, excluding those of embedded interfaces, which are
+// attributed to the interfaces that declare them.
+func ifaceMethods(owner *types.TypeName) []ifaceMethod {
+	iface, ok := owner.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	var methods []ifaceMethod
+	for i := 0; i < iface.NumExplicitMethods(); i++ {
+		methods = append(methods, ifaceMethod{owner, iface.ExplicitMethod(i)})
+	}
+	return methods
+}
+
+// uncalledMethods returns the interface methods that are never the
+// target of a dynamic call within a reachable function.
+//
+// The call sites of a method refer to the method of the interface
+// type of the operand, which may be another interface that the
+// values of the method's interface are converted to, such as
+// io.Writer. So the result is conservative: a method is called if
+// any reachable dynamic call invokes a method of the same name
+// (and package, if unexported) and an identical signature.
+// Calls through reflection are not considered.
+func uncalledMethods(res *rta.Result, methods []ifaceMethod) []ifaceMethod {
+	invoked := make(map[string][]*types.Signature) // signatures of invoked methods, by Id
+	for fn := range res.Reachable {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(ssa.CallInstruction)
+				if !ok || !call.Common().IsInvoke() {
+					continue
+				}
+				m := call.Common().Method.Origin() // generic method, if instantiated
+				invoked[m.Id()] = append(invoked[m.Id()], m.Type().(*types.Signature))
+			}
+		}
+	}
+	var uncalled []ifaceMethod
+	for _, m := range methods {
+		sig := m.method.Type().(*types.Signature)
+		called := containsFunc(invoked[m.method.Id()], func(s *types.Signature) bool {
+			return types.Identical(s, sig) // (ignores receivers)
+		})
+		if !called {
+			uncalled = append(uncalled, m)
+		}
+	}
+	return uncalled
+}
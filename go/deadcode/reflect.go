@@ -0,0 +1,134 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// This file defines the support for [Config.Reflection].
+//
+// RTA considers the exported methods of every type that may appear
+// in an interface (and thus in a reflect.Value) to be reachable.
+// A program that calls methods by name through reflection may
+// nonetheless reach methods of other types, for example those
+// obtained from a plugin, or constructed from type information
+// the analysis cannot track.
+
+// reflectiveMethods are the methods of reflect.Value and reflect.Type
+// that are used to call methods chosen at run time.
+var reflectiveMethods = map[string]bool{
+	"Call":         true,
+	"CallSlice":    true,
+	"Method":       true,
+	"MethodByName": true,
+}
+
+// findReflectiveCall returns a reachable function outside package
+// reflect that calls one of the reflectiveMethods, along with the
+// full name of that method. If there are several such functions, it
+// returns the first by position, for determinism. It returns a nil
+// function if there are none.
+func findReflectiveCall(reachable map[*ssa.Function]struct{ AddrTaken bool }) (*ssa.Function, string) {
+	type call struct {
+		caller *ssa.Function
+		method string
+	}
+	var calls []call
+	for fn := range reachable {
+		if fn.Pkg == nil || fn.Pkg.Pkg.Path() == "reflect" {
+			continue // wrapper, or part of reflect itself
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				site, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				var method *types.Func
+				if common := site.Common(); common.IsInvoke() {
+					method = common.Method // e.g. reflect.Type.MethodByName
+				} else if callee := common.StaticCallee(); callee != nil {
+					method, _ = callee.Object().(*types.Func) // e.g. reflect.Value.Call
+				}
+				if method != nil &&
+					method.Pkg() != nil && method.Pkg().Path() == "reflect" &&
+					method.Type().(*types.Signature).Recv() != nil &&
+					reflectiveMethods[method.Name()] {
+					calls = append(calls, call{fn, method.FullName()})
+				}
+			}
+		}
+	}
+	if len(calls) == 0 {
+		return nil, ""
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		x, y := calls[i].caller, calls[j].caller
+		if x.Pos() != y.Pos() {
+			return x.Pos() < y.Pos()
+		}
+		return x.String() < y.String()
+	})
+	return calls[0].caller, calls[0].method
+}
+
+// reflectionRoots returns the exported methods of every non-generic
+// named type declared in the program, and of pointers to them, any
+// of which a program using reflection may conceivably call.
+func reflectionRoots(prog *ssa.Program) []*ssa.Function {
+	return exportedMethods(prog, prog.AllPackages())
+}
+
+// exportedMethods returns the exported methods of every non-generic
+// named type declared in the specified packages, and of pointers to
+// them, in a deterministic order. In place of a wrapper, such as that
+// of a promoted method, it returns the method it wraps.
+func exportedMethods(prog *ssa.Program, pkgs []*ssa.Package) []*ssa.Function {
+	var roots []*ssa.Function
+	for _, pkg := range pkgs {
+		for _, mem := range pkg.Members {
+			t, ok := mem.(*ssa.Type)
+			if !ok {
+				continue
+			}
+			T := t.Type()
+			if types.IsInterface(T) {
+				continue
+			}
+			if named, ok := T.(*types.Named); !ok || named.TypeParams().Len() > 0 {
+				continue // alias, or generic type
+			}
+			for _, T := range []types.Type{T, types.NewPointer(T)} {
+				mset := prog.MethodSets.MethodSet(T)
+				for i := 0; i < mset.Len(); i++ {
+					sel := mset.At(i)
+					if !sel.Obj().Exported() {
+						continue
+					}
+					fn := prog.MethodValue(sel)
+					if fn.Synthetic != "" {
+						// A wrapper, such as for a promoted method,
+						// belongs to no package: use the method it
+						// wraps, unless that is generic.
+						fn = prog.FuncValue(sel.Obj().(*types.Func))
+					}
+					if fn != nil && fn.Pkg != nil && fn.TypeParams().Len() == 0 {
+						roots = append(roots, fn)
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].String() < roots[j].String()
+	})
+	return roots
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// liveTypes returns the positions of the package-level named types
+// that are used by reachable code, for [Config.Types].
+//
+// A type is used if it is referenced from anywhere but the
+// declaration of a dead function, as indicated by reachablePosn, or
+// its own declaration. This includes references from the receivers
+// of reachable methods, from the fields of other types (even unused
+// ones), and from the declarations of variables and constants. So,
+// like the treatment of constants by [liveGlobals], the result is
+// conservative.
+//
+// Interface types need no special treatment: since a concrete type
+// satisfies an interface implicitly, without mentioning it, only a
+// reference by name makes an interface used. Conversely, a concrete
+// type that is used only through an interface is still referenced
+// by the code that creates its values.
+func liveTypes(fset *token.FileSet, initial []*packages.Package, reachablePosn map[token.Position]bool) map[token.Position]bool {
+	live := make(map[token.Position]bool)
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		// inspect marks the types referenced within n,
+		// apart from self, as used.
+		inspect := func(n ast.Node, self types.Object) {
+			ast.Inspect(n, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.FuncDecl:
+					// Skip the declarations of dead functions.
+					return reachablePosn[fset.Position(n.Name.Pos())]
+
+				case *ast.Ident:
+					if obj, ok := p.TypesInfo.Uses[n].(*types.TypeName); ok &&
+						obj != self && obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() {
+						live[fset.Position(obj.Pos())] = true
+					}
+				}
+				return true
+			})
+		}
+		for _, file := range p.Syntax {
+			for _, decl := range file.Decls {
+				if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.TYPE {
+					for _, spec := range decl.Specs {
+						spec := spec.(*ast.TypeSpec)
+						inspect(spec, p.TypesInfo.Defs[spec.Name])
+					}
+					continue
+				}
+				inspect(decl, nil)
+			}
+		}
+	})
+	return live
+}
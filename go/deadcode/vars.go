@@ -0,0 +1,69 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package deadcode
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// liveGlobals returns the positions of the package-level variables
+// and constants that are used by reachable code, for [Config.Vars].
+//
+// A variable is used if a reachable function loads from it, or takes
+// its address for any purpose other than storing to it. (The package
+// initializer stores to each variable that has an initializer
+// expression; this alone does not make it live.)
+//
+// Constants do not survive the translation to SSA form, so instead we
+// consider a constant used if it is referenced from anywhere but the
+// declaration of a dead function, as indicated by reachablePosn.
+func liveGlobals(fset *token.FileSet, initial []*packages.Package, reachable map[*ssa.Function]struct{ AddrTaken bool }, reachablePosn map[token.Position]bool) map[token.Position]bool {
+	live := make(map[token.Position]bool)
+
+	// Variables.
+	var space [32]*ssa.Value // preallocate space for common case
+	for fn := range reachable {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				for _, op := range instr.Operands(space[:0]) {
+					if g, ok := (*op).(*ssa.Global); ok {
+						if store, ok := instr.(*ssa.Store); ok && op == &store.Addr {
+							continue // a store is not a use
+						}
+						live[fset.Position(g.Pos())] = true
+					}
+				}
+			}
+		}
+	}
+
+	// Constants.
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		for _, file := range p.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.FuncDecl:
+					// Skip the declarations of dead functions.
+					return reachablePosn[fset.Position(n.Name.Pos())]
+
+				case *ast.Ident:
+					if obj, ok := p.TypesInfo.Uses[n].(*types.Const); ok && obj.Parent() == obj.Pkg().Scope() {
+						live[fset.Position(obj.Pos())] = true
+					}
+				}
+				return true
+			})
+		}
+	})
+
+	return live
+}